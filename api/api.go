@@ -32,6 +32,11 @@ func InitService(name, version string) {
 	}
 }
 
+// GetServiceInfo returns the service info set by InitService
+func GetServiceInfo() *ServiceInfo {
+	return serviceInfo
+}
+
 // Basic Handler func ---------------------------------------------------------------
 
 // IndexHandeler common index handler for all the service
@@ -0,0 +1,231 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/manigandand/adk/errors"
+	"github.com/manigandand/adk/middleware"
+	"github.com/manigandand/adk/respond"
+)
+
+// Default batch limits, exported so that it can be changed by developers
+var (
+	BatchMaxRequests = 20
+	BatchConcurrency = 5
+)
+
+type contextKey string
+
+// batchKey marks the sub requests of the batch, so the nested batches are
+// rejected wherever the batch handler is mounted
+const batchKey contextKey = "api.batch"
+
+// BatchRequest is a sub request of the batch
+type BatchRequest struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// Validate implements the custom validator interface
+func (b *BatchRequest) Validate() *errors.AppError {
+	if b.Method == "" {
+		return errors.KeyRequired("method")
+	}
+	if b.Path == "" {
+		return errors.KeyRequired("path")
+	}
+	if !strings.HasPrefix(b.Path, "/") {
+		return errors.InvalidKey(b.Path, "path")
+	}
+	return nil
+}
+
+// BatchResponse is the response of the sub request. Body is the raw JSON of
+// the JSON responses, else the response is sent as JSON string.
+type BatchResponse struct {
+	Status  int             `json:"status"`
+	Headers http.Header     `json:"headers,omitempty"`
+	Body    json.RawMessage `json:"body,omitempty"`
+}
+
+// BatchHandler executes the batch of sub requests through the router and
+// responds with the sub responses in the same order.
+// The sub requests inherit the headers of the batch request, the sub request
+// headers override them. Each sub request gets the request id derived from the
+// batch request id, Ex: <request-id>-1, which is logged by middleware.Logger.
+//
+// EX:
+//
+//	r := chi.NewRouter()
+//	r.Use(middleware.Logger)
+//	r.Use(middleware.Recoverer)
+//	r.Method(http.MethodGet, "/user/{id}", api.Handler(GetUserHandler))
+//	r.Method(http.MethodPost, "/batch", api.NewBatchHandler(r))
+//
+//	POST /batch
+//	[
+//		{"method": "GET", "path": "/user/1"},
+//		{"method": "GET", "path": "/user/2"}
+//	]
+type BatchHandler struct {
+	Router http.Handler
+	// Path of the batch endpoint, sub requests to this path are rejected
+	// upfront. The nested batches are rejected on any path, by the context
+	// of the sub requests.
+	Path string
+	// MaxRequests is the max number of sub requests allowed in a batch
+	MaxRequests int
+	// Concurrency is the max number of sub requests executed in parallel
+	Concurrency int
+}
+
+// NewBatchHandler returns the batch handler which dispatches the sub requests
+// to the router.
+func NewBatchHandler(router http.Handler) *BatchHandler {
+	return &BatchHandler{
+		Router:      router,
+		Path:        "/batch",
+		MaxRequests: BatchMaxRequests,
+		Concurrency: BatchConcurrency,
+	}
+}
+
+// ServeHTTP implements http handler interface
+func (b *BatchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	Handler(b.serve).ServeHTTP(w, r)
+}
+
+func (b *BatchHandler) serve(w http.ResponseWriter, r *http.Request) *errors.AppError {
+	if nested, _ := r.Context().Value(batchKey).(bool); nested {
+		return errors.BadRequest("nested batch requests are not allowed")
+	}
+
+	var reqs []*BatchRequest
+	if err := Decode(r, &reqs); err != nil {
+		return err
+	}
+	if len(reqs) == 0 {
+		return errors.BadRequest("batch is empty")
+	}
+	if b.MaxRequests > 0 && len(reqs) > b.MaxRequests {
+		return errors.BadRequest(fmt.Sprintf("batch can't have more than %d requests", b.MaxRequests))
+	}
+	for i, req := range reqs {
+		if b.Path != "" && strings.SplitN(req.Path, "?", 2)[0] == b.Path {
+			return errors.BadRequest("nested batch requests are not allowed").
+				WithField(fmt.Sprintf("[%d].path", i))
+		}
+	}
+
+	concurrency := b.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	var (
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, concurrency)
+		resp = make([]*BatchResponse, len(reqs))
+	)
+	for i, req := range reqs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, req *BatchRequest) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			resp[i] = b.do(r, i, req)
+		}(i, req)
+	}
+	wg.Wait()
+
+	return respond.OK(w, resp)
+}
+
+// do executes the sub request through the router
+func (b *BatchHandler) do(parent *http.Request, i int, req *BatchRequest) (res *BatchResponse) {
+	defer func() {
+		// routers without the Recoverer middleware
+		if rvr := recover(); rvr != nil {
+			middleware.LogPanic(rvr, req.Method+" "+req.Path)
+			res = batchErrResponse(errors.InternalServerStd().WithNoLog())
+		}
+	}()
+
+	// chi reuses the routing context found in the request context, reset it to
+	// route the sub request from the root of the router
+	ctx := context.WithValue(parent.Context(), chi.RouteCtxKey, nil)
+	ctx = context.WithValue(ctx, batchKey, true)
+
+	subReq, err := http.NewRequestWithContext(ctx, req.Method, req.Path, bytes.NewReader(req.Body))
+	if err != nil {
+		return batchErrResponse(errors.BadRequest("invalid batch request").AddDebug(err))
+	}
+
+	for key, values := range parent.Header {
+		if key == "Content-Length" {
+			continue
+		}
+		subReq.Header[key] = append([]string(nil), values...)
+	}
+	for key, value := range req.Headers {
+		subReq.Header.Set(key, value)
+	}
+	if len(req.Body) > 0 && subReq.Header.Get("Content-Type") == "" {
+		subReq.Header.Set("Content-Type", "application/json")
+	}
+	subReq.RemoteAddr = parent.RemoteAddr
+	subReq.Host = parent.Host
+
+	reqID := getReqID(parent)
+	if reqID != "" {
+		subReq.Header.Set(middleware.RequestIDHeader, fmt.Sprintf("%s-%d", reqID, i+1))
+	}
+
+	rec := httptest.NewRecorder()
+	b.Router.ServeHTTP(rec, subReq)
+
+	result := rec.Result()
+	return &BatchResponse{
+		Status:  result.StatusCode,
+		Headers: result.Header,
+		Body:    jsonBody(rec.Body.Bytes()),
+	}
+}
+
+func batchErrResponse(err *errors.AppError) *BatchResponse {
+	err.Log()
+	body, _ := json.Marshal(err)
+	return &BatchResponse{
+		Status: err.GetStatus(),
+		Headers: http.Header{
+			"Content-Type": []string{"application/json"},
+		},
+		Body: body,
+	}
+}
+
+// jsonBody returns the body as raw JSON, non JSON bodies are quoted as JSON string
+func jsonBody(body []byte) json.RawMessage {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil
+	}
+	if json.Valid(body) {
+		return body
+	}
+
+	quoted, _ := json.Marshal(string(body))
+	return quoted
+}
@@ -0,0 +1,248 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/manigandand/adk/errors"
+	"github.com/manigandand/adk/middleware"
+)
+
+// CoalesceHeaders are the request headers in the coalescing key by default,
+// so the responses are never shared across the users. Exported so that it can
+// be changed by developers
+var CoalesceHeaders = []string{
+	"Authorization", "Cookie", "Accept", "Accept-Encoding", "Accept-Language",
+}
+
+// Coalescer collapses the identical concurrent requests into one handler run,
+// the buffered response (status, headers & body, or the app error) is shared
+// with all the waiting requests. The requests are identical when the method,
+// the path, the QueryParams, the Headers and the Tenant are the same.
+//
+// The handler runs with the context detached from the requests, it's cancelled
+// only when all the waiting clients are gone. So the leader's disconnect
+// doesn't fail the rest of the waiters.
+//
+// EX:
+//
+//	coalesce := api.NewCoalescer()
+//	coalesce.QueryParams = []string{"currency"}
+//	coalesce.Tenant = func(r *http.Request) string { return r.Header.Get("X-Tenant-Id") }
+//
+//	r.Method(http.MethodGet, "/reports/{id}", api.Handler(ReportHandler).Use(coalesce.Middleware))
+type Coalescer struct {
+	// Methods are the coalesced methods, the other requests pass through
+	Methods []string
+	// QueryParams are the query params in the key, nil is the whole query
+	QueryParams []string
+	// Headers are the request headers in the key
+	Headers []string
+	// Tenant returns the tenant of the request, which is in the key
+	Tenant func(r *http.Request) string
+
+	mu    sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+// NewCoalescer returns the coalescer of the GET & HEAD requests
+func NewCoalescer() *Coalescer {
+	return &Coalescer{
+		Methods: []string{http.MethodGet, http.MethodHead},
+		Headers: CoalesceHeaders,
+		calls:   map[string]*coalescedCall{},
+	}
+}
+
+// coalescedCall is the in-flight handler run
+type coalescedCall struct {
+	done    chan struct{}
+	waiters int
+	cancel  context.CancelFunc
+
+	res *httptest.ResponseRecorder
+	err *errors.AppError
+}
+
+// Middleware implements Middleware
+func (c *Coalescer) Middleware(next Handler) Handler {
+	return func(w http.ResponseWriter, r *http.Request) *errors.AppError {
+		if !c.coalesced(r) {
+			return next(w, r)
+		}
+
+		key := c.key(r)
+		call, leader := c.join(key)
+		if leader {
+			// detached before the leader returns, the router may recycle
+			// the request state then
+			req, cancel := detach(r)
+			go c.run(key, call, next, req, cancel)
+		}
+
+		select {
+		case <-call.done:
+		case <-r.Context().Done():
+			c.leave(key, call)
+			return nil
+		}
+		return call.write(w)
+	}
+}
+
+func (c *Coalescer) coalesced(r *http.Request) bool {
+	for _, method := range c.Methods {
+		if r.Method == method {
+			return true
+		}
+	}
+	return false
+}
+
+// key returns the hash of the request identity, so the credentials are not
+// kept in the memory.
+func (c *Coalescer) key(r *http.Request) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n", r.Method, r.URL.Path)
+
+	query := r.URL.Query()
+	if c.QueryParams != nil {
+		selected := url.Values{}
+		for _, param := range c.QueryParams {
+			if values, ok := query[param]; ok {
+				selected[param] = values
+			}
+		}
+		query = selected
+	}
+	// Encode sorts by the key
+	fmt.Fprintf(h, "%s\n", query.Encode())
+
+	headers := append([]string(nil), c.Headers...)
+	sort.Strings(headers)
+	for _, key := range headers {
+		fmt.Fprintf(h, "%s:%s\n", strings.ToLower(key), strings.Join(r.Header.Values(key), ","))
+	}
+	if c.Tenant != nil {
+		fmt.Fprintf(h, "tenant:%s\n", c.Tenant(r))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// join joins the in-flight call of the key or starts the new one
+func (c *Coalescer) join(key string) (*coalescedCall, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.calls == nil {
+		c.calls = map[string]*coalescedCall{}
+	}
+	if call, ok := c.calls[key]; ok {
+		call.waiters++
+		return call, false
+	}
+
+	call := &coalescedCall{done: make(chan struct{}), waiters: 1}
+	c.calls[key] = call
+	return call, true
+}
+
+// leave removes the waiter, the handler is cancelled when all the waiters are gone
+func (c *Coalescer) leave(key string, call *coalescedCall) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	call.waiters--
+	if call.waiters > 0 {
+		return
+	}
+	// the new requests start the new call
+	if c.calls[key] == call {
+		delete(c.calls, key)
+	}
+	if call.cancel != nil {
+		call.cancel()
+	}
+}
+
+// run runs the handler with the detached context & the buffered response, the
+// panics are recovered & logged as the middleware.Recoverer, as the handler
+// doesn't run on the request goroutine.
+func (c *Coalescer) run(key string, call *coalescedCall, next Handler, r *http.Request, cancel context.CancelFunc) {
+	c.mu.Lock()
+	call.cancel = cancel
+	if call.waiters == 0 {
+		cancel()
+	}
+	c.mu.Unlock()
+
+	res := httptest.NewRecorder()
+	defer func() {
+		if rvr := recover(); rvr != nil {
+			middleware.LogPanic(rvr, r.URL.String())
+			call.err = errors.InternalServerStd()
+		}
+
+		c.mu.Lock()
+		if c.calls[key] == call {
+			delete(c.calls, key)
+		}
+		c.mu.Unlock()
+
+		// logged once, the waiters respond the copies without logging
+		if call.err != nil {
+			call.err.Log()
+		}
+		cancel()
+		call.res = res
+		close(call.done)
+	}()
+
+	call.err = next(res, r)
+}
+
+// detach returns the request with the context detached from the request
+// cancellation. chi puts its route context back in the pool once the request
+// returns, so the route patterns & the url params are copied into the new one.
+func detach(r *http.Request) (*http.Request, context.CancelFunc) {
+	ctx := context.WithoutCancel(r.Context())
+	if rctx := chi.RouteContext(ctx); rctx != nil {
+		copied := chi.NewRouteContext()
+		copied.Routes = rctx.Routes
+		copied.RoutePath = rctx.RoutePath
+		copied.RouteMethod = rctx.RouteMethod
+		copied.RoutePatterns = append([]string(nil), rctx.RoutePatterns...)
+		copied.URLParams.Keys = append([]string(nil), rctx.URLParams.Keys...)
+		copied.URLParams.Values = append([]string(nil), rctx.URLParams.Values...)
+		ctx = context.WithValue(ctx, chi.RouteCtxKey, copied)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	return r.WithContext(ctx), cancel
+}
+
+// write writes the shared response
+func (call *coalescedCall) write(w http.ResponseWriter) *errors.AppError {
+	if call.err != nil {
+		return call.err.Clone().WithNoLog()
+	}
+
+	res := call.res.Result()
+	for key, values := range res.Header {
+		w.Header()[key] = append([]string(nil), values...)
+	}
+	w.WriteHeader(res.StatusCode)
+	if _, err := w.Write(call.res.Body.Bytes()); err != nil {
+		return errors.InternalServer("couldn't write the response").AddDebug(err).WithNoLog()
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/manigandand/adk/errors"
+	"github.com/manigandand/adk/respond"
+)
+
+func TestCoalescerLeaderDisconnect(t *testing.T) {
+	var runs atomic.Int32
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	coalesce := NewCoalescer()
+	r := chi.NewRouter()
+	r.Method(http.MethodGet, "/items/{id}", Handler(func(w http.ResponseWriter, r *http.Request) *errors.AppError {
+		if runs.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		return respond.OK(w, map[string]interface{}{
+			"id":    chi.URLParam(r, "id"),
+			"param": PathParam(r, "id"),
+			"route": RoutePattern(r),
+		})
+	}).Use(coalesce.Middleware))
+	r.Get("/other/{name}", func(w http.ResponseWriter, r *http.Request) {})
+
+	// the leader disconnects while the handler runs
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		req := httptest.NewRequest(http.MethodGet, "/items/1", nil).WithContext(leaderCtx)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	<-started
+
+	follower := httptest.NewRecorder()
+	followerDone := make(chan struct{})
+	go func() {
+		defer close(followerDone)
+		r.ServeHTTP(follower, httptest.NewRequest(http.MethodGet, "/items/1", nil))
+	}()
+	waitWaiters(t, coalesce, 2)
+
+	cancelLeader()
+	<-leaderDone
+
+	// reuses the route context pooled by chi after the leader returned
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/other/2", nil))
+
+	close(release)
+	<-followerDone
+
+	if got := runs.Load(); got != 1 {
+		t.Errorf("handler runs = %d, want 1", got)
+	}
+	if follower.Code != http.StatusOK {
+		t.Fatalf("follower status = %d, want 200", follower.Code)
+	}
+	want := `{"id":"1","param":"1","route":"/items/{id}"}`
+	if got := strings.TrimSpace(follower.Body.String()); got != want {
+		t.Errorf("follower body = %s, want %s", got, want)
+	}
+}
+
+func TestCoalescerCancelsWhenAllWaitersLeave(t *testing.T) {
+	cancelled := make(chan struct{})
+	coalesce := NewCoalescer()
+	h := Handler(func(w http.ResponseWriter, r *http.Request) *errors.AppError {
+		<-r.Context().Done()
+		close(cancelled)
+		return nil
+	}).Use(coalesce.Middleware)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil).WithContext(ctx))
+	waitWaiters(t, coalesce, 1)
+	cancel()
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("handler context is not cancelled after all the waiters left")
+	}
+}
+
+// waitWaiters waits until the in-flight call has n waiters
+func waitWaiters(t *testing.T, c *Coalescer, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		for _, call := range c.calls {
+			if call.waiters == n {
+				c.mu.Unlock()
+				return
+			}
+		}
+		c.mu.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("coalesced call doesn't have %d waiters", n)
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/manigandand/adk/errors"
+)
+
+// DecodeErrorDetail describes where and why the request payload couldn't be
+// decoded. It is sent to the client as the conflict_data of the AppError.
+//
+// EX:
+//
+//	{
+//		"status": 422,
+//		"error": "age must be a number",
+//		"conflict_data": {
+//			"field": "age",
+//			"expected": "number",
+//			"actual": "string",
+//			"offset": 27,
+//			"line": 3,
+//			"column": 11
+//		},
+//		"error_details": {"code": "D0003", ...}
+//	}
+type DecodeErrorDetail struct {
+	Field    string `json:"field,omitempty"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+	Offset   int64  `json:"offset"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+}
+
+// decodeError translates the encoding/json errors into a client friendly app error.
+// data is the raw request payload, used to find the line and column of the error.
+func decodeError(data []byte, err error) *errors.AppError {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		appErr    *errors.AppError
+	)
+
+	switch {
+	case errors.As(err, &appErr):
+		// custom UnmarshalJSON / UnmarshalText already returns the app error
+		return appErr
+
+	case err == io.EOF || len(bytes.TrimSpace(data)) == 0:
+		return errors.UnprocessableEntity("request payload is empty").
+			AddErrorDetail(errors.EmptyPayload.Form("expected a JSON payload")).
+			AddDebug(err)
+
+	case err == io.ErrUnexpectedEOF:
+		detail := newDecodeErrorDetail(data, int64(len(data)))
+		return errors.UnprocessableEntity(fmt.Sprintf(
+			"request payload is truncated at line %d, column %d", detail.Line, detail.Column,
+		)).
+			AddConflictData(detail).
+			AddErrorDetail(errors.MalformedPayload.Form("unexpected end of JSON input")).
+			AddDebug(err)
+
+	case errors.As(err, &syntaxErr):
+		detail := newDecodeErrorDetail(data, syntaxErr.Offset)
+		return errors.UnprocessableEntity(fmt.Sprintf(
+			"request payload has invalid JSON at line %d, column %d", detail.Line, detail.Column,
+		)).
+			AddConflictData(detail).
+			AddErrorDetail(errors.MalformedPayload.Form(syntaxErr.Error())).
+			AddDebug(err)
+
+	case errors.As(err, &typeErr):
+		detail := newDecodeErrorDetail(data, typeErr.Offset)
+		detail.Field = fieldPath(typeErr.Field)
+		detail.Expected = jsonTypeName(typeErr.Type)
+		detail.Actual = typeErr.Value
+
+		field := detail.Field
+		if field == "" {
+			field = "request payload"
+		}
+		return errors.UnprocessableEntity(field + " must be " + withArticle(detail.Expected)).
+			WithField(detail.Field).
+			AddConflictData(detail).
+			AddErrorDetail(errors.InvalidFieldType.Form(typeErr.Error())).
+			AddDebug(err)
+
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json doesn't export a type for the unknown field error
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		detail := newDecodeErrorDetail(data, unknownFieldOffset(data, field))
+		detail.Field = field
+		return errors.UnprocessableEntity(field + " is not a known field").
+			WithField(field).
+			AddConflictData(detail).
+			AddErrorDetail(errors.UnknownField.Form(err.Error())).
+			AddDebug(err)
+	}
+
+	return errors.UnprocessableEntity("unmarshal request payload").
+		AddErrorDetail(errors.MalformedPayload.Form(err.Error())).
+		AddDebug(err)
+}
+
+// newDecodeErrorDetail computes the 1 based line and column of the last byte
+// read before the decoder failed.
+func newDecodeErrorDetail(data []byte, offset int64) *DecodeErrorDetail {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	pos := int(offset) - 1
+	if pos < 0 {
+		pos = 0
+	}
+	head := data[:pos]
+
+	return &DecodeErrorDetail{
+		Offset: offset,
+		Line:   bytes.Count(head, []byte("\n")) + 1,
+		Column: pos - bytes.LastIndexByte(head, '\n'),
+	}
+}
+
+// fieldPath converts the encoding/json dotted field path into the
+// items[3].sku form.
+func fieldPath(field string) string {
+	if field == "" {
+		return ""
+	}
+
+	var path strings.Builder
+	for i, part := range strings.Split(field, ".") {
+		if _, err := strconv.Atoi(part); err == nil {
+			path.WriteString("[" + part + "]")
+			continue
+		}
+		if i > 0 {
+			path.WriteByte('.')
+		}
+		path.WriteString(part)
+	}
+	return path.String()
+}
+
+// unknownFieldOffset returns the offset of the first occurrence of the key in the payload.
+func unknownFieldOffset(data []byte, field string) int64 {
+	key, _ := json.Marshal(field)
+	if i := bytes.Index(data, key); i >= 0 {
+		return int64(i + 1)
+	}
+	return 0
+}
+
+// jsonTypeName returns the JSON type name of the go type.
+func jsonTypeName(t reflect.Type) string {
+	if t == nil {
+		return ""
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Implements(textUnmarshalerType) || reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return "string"
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	}
+
+	return t.String()
+}
+
+func withArticle(typ string) string {
+	switch {
+	case typ == "":
+		return "a different type"
+	case strings.ContainsAny(typ[:1], "aeiou"):
+		return "an " + typ
+	}
+	return "a " + typ
+}
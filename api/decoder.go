@@ -1,9 +1,16 @@
 package api
 
 import (
+	"bytes"
+	"context"
+	"encoding"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"reflect"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
@@ -17,7 +24,19 @@ type ok interface {
 	Validate() *errors.AppError
 }
 
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// MaxBodySize is the max size of the request body read by Decode & DecodeStrict,
+// the larger body fails with 413. 0 disables the limit.
+// Exported so that it can be changed by developers
+var MaxBodySize int64 = 10 << 20
+
 // Decode - decodes the request body and extends the validator interface with the Validate() method
+// Validate() and ValidateContext(ctx) are invoked on the nested structs, slice elements
+// and map values as well. see Validate.
+// The normalize tags are applied before the validation. see NormalizeTag.
+// JSON syntax & type errors are reported back to the client with the field, expected
+// type, offset, line and column of the error. see DecodeErrorDetail.
 //
 // EX:
 // type User struct {
@@ -32,16 +51,97 @@ type ok interface {
 // 	return nil
 // }
 func Decode(r *http.Request, v interface{}) *errors.AppError {
-	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
-		return errors.UnprocessableEntity("unmarshal request payload").
-			AddDebug(err)
+	return decode(r, v, false)
+}
+
+// DecodeStrict works as Decode, but the request payload fails to decode if it
+// contains any field which is not known by v.
+func DecodeStrict(r *http.Request, v interface{}) *errors.AppError {
+	return decode(r, v, true)
+}
+
+func decode(r *http.Request, v interface{}, strict bool) *errors.AppError {
+	body := r.Body
+	if MaxBodySize > 0 {
+		body = http.MaxBytesReader(nil, r.Body, MaxBodySize)
 	}
 
-	// custom validator interface
-	if payload, ok := v.(ok); ok {
-		return payload.Validate()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		// the body readers may fail with the app error. Ex: versioning upgrades
+		var appErr *errors.AppError
+		if errors.As(err, &appErr) {
+			return appErr
+		}
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return errors.RequestEntityTooLarge(fmt.Sprintf("request payload exceeds %d bytes", maxErr.Limit))
+		}
+		return errors.BadRequest("couldn't read request payload").AddDebug(err)
 	}
-	return nil
+
+	return decodeJSON(r.Context(), data, v, strict)
+}
+
+// DecodeJSON works as Decode on the raw JSON payload, used to decode the
+// payloads which are not the request body. Ex: JSON-RPC params, queue messages
+func DecodeJSON(ctx context.Context, data []byte, v interface{}) *errors.AppError {
+	return decodeJSON(ctx, data, v, false)
+}
+
+func decodeJSON(ctx context.Context, data []byte, v interface{}, strict bool) *errors.AppError {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(v); err != nil {
+		return decodeError(data, err)
+	}
+
+	if err := Normalize(v); err != nil {
+		return err
+	}
+
+	// custom validator interfaces, on v and all of its nested values
+	return Validate(ctx, v)
+}
+
+// DecodeQuery decodes the url query params into v using the FormDecoder,
+// applies the normalize tags and invokes the custom validator interfaces.
+//
+// EX:
+//
+//	type listUsersReq struct {
+//		Email string `schema:"email" normalize:"trim,lower"`
+//		Limit int    `schema:"limit"`
+//	}
+func DecodeQuery(r *http.Request, v interface{}) *errors.AppError {
+	query := r.URL.Query()
+	if err := FormDecoder.Decode(v, query); err != nil {
+		return schemaError(err, query, "invalid query params")
+	}
+
+	if err := Normalize(v); err != nil {
+		return err
+	}
+	return Validate(r.Context(), v)
+}
+
+// schemaError returns the invalid key error of the first invalid param
+func schemaError(err error, params url.Values, msg string) *errors.AppError {
+	mErr, ok := err.(schema.MultiError)
+	if !ok || len(mErr) == 0 {
+		return errors.BadRequest(msg).AddDebug(err)
+	}
+
+	keys := make([]string, 0, len(mErr))
+	for key := range mErr {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	key := keys[0]
+	return errors.InvalidKey(params.Get(key), key).AddDebug(mErr[key])
 }
 
 // JustDecode just decodes the request body
@@ -70,9 +170,32 @@ func init() {
 	FormDecoder = schema.NewDecoder()
 	FormDecoder.ZeroEmpty(true)
 	FormDecoder.IgnoreUnknownKeys(true)
-	FormDecoder.RegisterConverter(time.Time{}, parseFilterTime)
-	FormDecoder.RegisterConverter(uuid.NullUUID{}, parseFilterUUID)
-	FormDecoder.RegisterConverter(primitive.NilObjectID, parseFilterObjectID)
+	registerConverters(FormDecoder)
+}
+
+// RegisterConverter registers the custom decoder of the type with the
+// FormDecoder and the path params decoder, so the type can be used in the
+// query params & path params structs. The converter returns the zero
+// reflect.Value for the invalid values, which is responded as errors.InvalidKey.
+//
+// EX:
+//
+//	api.RegisterConverter(Color(""), func(s string) reflect.Value {
+//		if c, ok := colors[s]; ok {
+//			return reflect.ValueOf(c)
+//		}
+//		return reflect.Value{}
+//	})
+func RegisterConverter(value interface{}, converter schema.Converter) {
+	FormDecoder.RegisterConverter(value, converter)
+	pathDecoder.RegisterConverter(value, converter)
+}
+
+// registerConverters registers the custom decoders of the common types
+func registerConverters(d *schema.Decoder) {
+	d.RegisterConverter(time.Time{}, parseFilterTime)
+	d.RegisterConverter(uuid.NullUUID{}, parseFilterUUID)
+	d.RegisterConverter(primitive.NilObjectID, parseFilterObjectID)
 }
 
 // register custom decoder for time.Time
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeMaxBodySize(t *testing.T) {
+	defer func(size int64) { MaxBodySize = size }(MaxBodySize)
+	MaxBodySize = 16
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"within the limit", `{"sku": "abc"}`, 0},
+		{"exceeds the limit", `{"sku": "abcdefghijkl"}`, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			var v vItem
+			err := Decode(r, &v)
+			if tt.wantStatus == 0 {
+				if err != nil {
+					t.Fatalf("Decode() = %v", err)
+				}
+				return
+			}
+			if err == nil || err.GetStatus() != tt.wantStatus {
+				t.Fatalf("Decode() = %v, want the status %d", err, tt.wantStatus)
+			}
+		})
+	}
+
+	// 0 disables the limit
+	MaxBodySize = 0
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"sku": "`+strings.Repeat("x", 64)+`"}`))
+	if err := Decode(r, &vItem{}); err != nil {
+		t.Errorf("Decode() without the limit = %v", err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/manigandand/adk/errors"
+	"github.com/manigandand/adk/middleware"
 	"github.com/manigandand/adk/respond"
 )
 
@@ -34,5 +35,8 @@ func (fn Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 var RequestIDHeader = "X-Request-Id"
 
 func getReqID(r *http.Request) string {
-	return r.Context().Value(RequestIDHeader).(string)
+	if reqID := middleware.RequestID(r.Context()); reqID != "" {
+		return reqID
+	}
+	return r.Header.Get(RequestIDHeader)
 }
@@ -0,0 +1,268 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/manigandand/adk/errors"
+	"github.com/manigandand/adk/middleware"
+	"github.com/manigandand/adk/respond"
+)
+
+// JSON-RPC 2.0 error codes
+// https://www.jsonrpc.org/specification#error_object
+const (
+	RPCParseError     = -32700
+	RPCInvalidRequest = -32600
+	RPCMethodNotFound = -32601
+	RPCInvalidParams  = -32602
+	RPCInternalError  = -32603
+	RPCServerError    = -32000
+)
+
+const rpcVersion = "2.0"
+
+// RPCMethod is the JSON-RPC method handler, params is the raw JSON of the
+// request params. use DecodeJSON to decode & validate the params.
+type RPCMethod func(ctx context.Context, params json.RawMessage) (interface{}, *errors.AppError)
+
+// RPCFunc returns the RPCMethod which decodes the params into P using
+// DecodeJSON, so the normalize tags & the custom validator interfaces are
+// applied as in Decode.
+//
+// EX:
+//
+//	rpc.Register("user.create", api.RPCFunc(func(ctx context.Context, req *createUserReq) (interface{}, *errors.AppError) {
+//		return store.CreateUser(ctx, req)
+//	}))
+func RPCFunc[P any](fn func(ctx context.Context, params *P) (interface{}, *errors.AppError)) RPCMethod {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, *errors.AppError) {
+		params := new(P)
+		if len(raw) > 0 {
+			if err := DecodeJSON(ctx, raw, params); err != nil {
+				return nil, err
+			}
+		}
+		return fn(ctx, params)
+	}
+}
+
+// RPCRequest is the JSON-RPC request object. The request without the id is
+// a notification, which is not responded.
+type RPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+func (req *RPCRequest) isNotification() bool {
+	return len(req.ID) == 0
+}
+
+// RPCResponse is the JSON-RPC response object
+type RPCResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// RPCError is the JSON-RPC error object. Data holds the status, error_details,
+// conflict_data & field of the app error.
+type RPCError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// NewRPCError converts the app error into the JSON-RPC error object
+//
+//	400, 422 -> -32602 invalid params
+//	5XX      -> -32603 internal error
+//	others   -> -32000 server error
+func NewRPCError(err *errors.AppError) *RPCError {
+	code := RPCServerError
+	switch {
+	case err.GetStatus() == http.StatusBadRequest, err.GetStatus() == http.StatusUnprocessableEntity:
+		code = RPCInvalidParams
+	case err.IsInternalError():
+		code = RPCInternalError
+	}
+
+	// reuse the app error JSON as data, "error" is already the message
+	var data map[string]interface{}
+	if b, mErr := json.Marshal(err); mErr == nil {
+		_ = json.Unmarshal(b, &data)
+		delete(data, "error")
+	}
+
+	return &RPCError{
+		Code:    code,
+		Message: err.Error(),
+		Data:    data,
+	}
+}
+
+// RPC exposes the registered methods over a single JSON-RPC 2.0 endpoint.
+// Supports the batch calls & notifications.
+//
+// EX:
+//
+//	rpc := api.NewRPC()
+//	rpc.Register("user.create", api.RPCFunc(CreateUser))
+//	r.Method(http.MethodPost, "/rpc", rpc)
+type RPC struct {
+	mu      sync.RWMutex
+	methods map[string]RPCMethod
+	// MaxBatch is the max number of calls allowed in a batch
+	MaxBatch int
+}
+
+// NewRPC returns the new JSON-RPC endpoint
+func NewRPC() *RPC {
+	return &RPC{
+		methods:  make(map[string]RPCMethod),
+		MaxBatch: BatchMaxRequests,
+	}
+}
+
+// Register registers the method with the name
+func (s *RPC) Register(name string, fn RPCMethod) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.methods[name] = fn
+}
+
+func (s *RPC) method(name string) (RPCMethod, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	fn, ok := s.methods[name]
+	return fn, ok
+}
+
+// ServeHTTP implements http handler interface
+func (s *RPC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	Handler(s.serve).ServeHTTP(w, r)
+}
+
+func (s *RPC) serve(w http.ResponseWriter, r *http.Request) *errors.AppError {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return errors.BadRequest("couldn't read request payload").AddDebug(err)
+	}
+
+	data = bytes.TrimSpace(data)
+	if !json.Valid(data) {
+		return respond.OK(w, rpcErrResponse(nil, RPCParseError, "parse error"))
+	}
+
+	// single call
+	if data[0] != '[' {
+		res := s.call(r.Context(), data)
+		if res == nil {
+			return respond.NoContent(w, nil)
+		}
+		return respond.OK(w, res)
+	}
+
+	// batch call
+	var calls []json.RawMessage
+	if err := json.Unmarshal(data, &calls); err != nil || len(calls) == 0 {
+		return respond.OK(w, rpcErrResponse(nil, RPCInvalidRequest, "invalid request"))
+	}
+	if s.MaxBatch > 0 && len(calls) > s.MaxBatch {
+		return respond.OK(w, rpcErrResponse(nil, RPCInvalidRequest, "too many calls in the batch"))
+	}
+
+	responses := make([]*RPCResponse, 0, len(calls))
+	for _, call := range calls {
+		if res := s.call(r.Context(), call); res != nil {
+			responses = append(responses, res)
+		}
+	}
+	// batch of notifications
+	if len(responses) == 0 {
+		return respond.NoContent(w, nil)
+	}
+	return respond.OK(w, responses)
+}
+
+// call executes the single call, returns nil for the notifications
+func (s *RPC) call(ctx context.Context, data json.RawMessage) (res *RPCResponse) {
+	var req RPCRequest
+	if err := json.Unmarshal(data, &req); err != nil || req.JSONRPC != rpcVersion || req.Method == "" {
+		return rpcErrResponse(req.ID, RPCInvalidRequest, "invalid request")
+	}
+
+	fn, ok := s.method(req.Method)
+	if !ok {
+		if req.isNotification() {
+			return nil
+		}
+		return rpcErrResponse(req.ID, RPCMethodNotFound, "method not found")
+	}
+
+	defer func() {
+		if rvr := recover(); rvr != nil {
+			middleware.LogPanic(rvr, "jsonrpc method "+req.Method)
+			res = rpcErrResponse(req.ID, RPCInternalError, "internal error")
+			if req.isNotification() {
+				res = nil
+			}
+		}
+	}()
+
+	result, appErr := fn(ctx, req.Params)
+	if req.isNotification() {
+		if appErr != nil {
+			appErr.Log()
+		}
+		return nil
+	}
+
+	if appErr != nil {
+		appErr.Log()
+		return &RPCResponse{
+			JSONRPC: rpcVersion,
+			Error:   NewRPCError(appErr),
+			ID:      req.ID,
+		}
+	}
+
+	return &RPCResponse{
+		JSONRPC: rpcVersion,
+		Result:  rpcResult(result),
+		ID:      req.ID,
+	}
+}
+
+// rpcResult returns JSON null for the nil result, the result member is required
+// in the success response.
+func rpcResult(result interface{}) interface{} {
+	if result == nil {
+		return json.RawMessage("null")
+	}
+	return result
+}
+
+func rpcErrResponse(id json.RawMessage, code int, msg string) *RPCResponse {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+
+	return &RPCResponse{
+		JSONRPC: rpcVersion,
+		Error: &RPCError{
+			Code:    code,
+			Message: msg,
+		},
+		ID: id,
+	}
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/manigandand/adk/errors"
+)
+
+// Middleware wraps the api Handler. The middleware can short-circuit the
+// request by returning the app error without calling next and it can wrap the
+// app error returned by next. The errors are responded once via respond.Fail
+// by Handler.ServeHTTP, so the middlewares never write the error JSON.
+//
+// EX:
+//
+//	func RequireTenant(next api.Handler) api.Handler {
+//		return func(w http.ResponseWriter, r *http.Request) *errors.AppError {
+//			tenant, err := store.Tenant(r.Context(), r.Header.Get("X-Tenant-Id"))
+//			if err != nil {
+//				return err
+//			}
+//			return next(w, r.WithContext(WithTenant(r.Context(), tenant)))
+//		}
+//	}
+type Middleware func(next Handler) Handler
+
+// Chain composes the middlewares into one, the first middleware is the
+// outermost. Chain() returns the middleware which does nothing.
+//
+// EX:
+//
+//	secured := api.Chain(Authenticate, RequireTenant, api.HTTPMiddleware(cors.Handler))
+//	r.Method(http.MethodGet, "/user/{id}", secured.Then(GetUserHandler))
+func Chain(mws ...Middleware) Middleware {
+	return func(next Handler) Handler {
+		for i := len(mws) - 1; i >= 0; i-- {
+			next = mws[i](next)
+		}
+		return next
+	}
+}
+
+// Then wraps the handler with the middleware
+func (mw Middleware) Then(h Handler) Handler {
+	return mw(h)
+}
+
+// Append returns the new middleware chain with mws after mw
+func (mw Middleware) Append(mws ...Middleware) Middleware {
+	return Chain(append([]Middleware{mw}, mws...)...)
+}
+
+// Use wraps the handler with the middlewares, the first middleware is the outermost.
+func (fn Handler) Use(mws ...Middleware) Handler {
+	return Chain(mws...)(fn)
+}
+
+// Before returns the middleware which runs fn before the handler. fn may
+// return the new request, Ex: with the context values, or the app error to
+// short-circuit the request.
+//
+// EX:
+//
+//	auth := api.Before(func(r *http.Request) (*http.Request, *errors.AppError) {
+//		user, err := Authenticate(r)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return r.WithContext(WithUser(r.Context(), user)), nil
+//	})
+func Before(fn func(r *http.Request) (*http.Request, *errors.AppError)) Middleware {
+	return func(next Handler) Handler {
+		return func(w http.ResponseWriter, r *http.Request) *errors.AppError {
+			req, err := fn(r)
+			if err != nil {
+				return err
+			}
+			if req == nil {
+				req = r
+			}
+			return next(w, req)
+		}
+	}
+}
+
+// OnError returns the middleware which wraps the app errors returned by the
+// next handlers. Ex: add the debug info or map the store errors.
+func OnError(fn func(r *http.Request, err *errors.AppError) *errors.AppError) Middleware {
+	return func(next Handler) Handler {
+		return func(w http.ResponseWriter, r *http.Request) *errors.AppError {
+			if err := next(w, r); err != nil {
+				return fn(r, err)
+			}
+			return nil
+		}
+	}
+}
+
+// HTTPMiddleware adapts the net/http middleware into the api Middleware. The
+// app error returned by next is passed through the net/http middleware, so it's
+// still responded once at the top of the chain. If the net/http middleware
+// doesn't call next, Ex: responds by itself, the chain stops there.
+func HTTPMiddleware(mw func(http.Handler) http.Handler) Middleware {
+	return func(next Handler) Handler {
+		return func(w http.ResponseWriter, r *http.Request) *errors.AppError {
+			var appErr *errors.AppError
+			inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				appErr = next(w, r)
+			})
+
+			mw(inner).ServeHTTP(w, r)
+			return appErr
+		}
+	}
+}
+
+// ToHTTP adapts the api Middleware into the net/http middleware, so it can be
+// used with the router. Ex: r.Use(api.ToHTTP(Authenticate))
+// The app errors of the middleware are responded via respond.Fail.
+func ToHTTP(mw Middleware) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return mw(func(w http.ResponseWriter, r *http.Request) *errors.AppError {
+			next.ServeHTTP(w, r)
+			return nil
+		})
+	}
+}
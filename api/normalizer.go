@@ -0,0 +1,388 @@
+package api
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/manigandand/adk/errors"
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeTag is the struct tag used to normalize the string fields, the
+// normalizers are applied in the given order.
+//
+// EX:
+//
+//	type createUserReq struct {
+//		Email string `json:"email" normalize:"trim,lower"`
+//		Name  string `json:"name" normalize:"trim,nfc,collapse_spaces"`
+//		Bio   string `json:"bio" normalize:"strip_html,trim"`
+//	}
+const NormalizeTag = "normalize"
+
+// Normalizer normalizes the string value of a field
+type Normalizer func(string) string
+
+var (
+	normalizersMu sync.RWMutex
+	normalizers   = map[string]Normalizer{
+		"trim":            strings.TrimSpace,
+		"lower":           strings.ToLower,
+		"upper":           strings.ToUpper,
+		"nfc":             norm.NFC.String,
+		"nfkc":            norm.NFKC.String,
+		"collapse_spaces": collapseSpaces,
+		"strip_html":      stripHTML,
+	}
+)
+
+// RegisterNormalizer registers the custom normalizer which can be used in the
+// normalize tag. Registering an existing name overrides the normalizer.
+//
+// EX:
+//
+//	api.RegisterNormalizer("digits", func(s string) string {
+//		return strings.Map(func(r rune) rune {
+//			if unicode.IsDigit(r) {
+//				return r
+//			}
+//			return -1
+//		}, s)
+//	})
+func RegisterNormalizer(name string, fn Normalizer) {
+	normalizersMu.Lock()
+	defer normalizersMu.Unlock()
+
+	normalizers[name] = fn
+}
+
+func getNormalizer(name string) (Normalizer, bool) {
+	normalizersMu.RLock()
+	defer normalizersMu.RUnlock()
+
+	fn, ok := normalizers[name]
+	return fn, ok
+}
+
+// Normalize applies the normalize tags on the string fields of v and all of
+// its nested structs, slice elements and map values. v must be a pointer.
+// Decode and DecodeQuery normalize the payload before the validation.
+// supported field types: string, *string, []string and map[string]string.
+// It panics on the unknown normalizer or the unsupported field type, the
+// tags are checked when the type is first seen.
+func Normalize(v interface{}) *errors.AppError {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return nil
+	}
+
+	return normalizeValue(rv.Elem(), make(map[uintptr]bool))
+}
+
+func normalizeValue(rv reflect.Value, seen map[uintptr]bool) *errors.AppError {
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() || seen[rv.Pointer()] {
+			return nil
+		}
+		seen[rv.Pointer()] = true
+		return normalizeValue(rv.Elem(), seen)
+
+	case reflect.Interface:
+		if rv.IsNil() || rv.Elem().Kind() != reflect.Ptr {
+			return nil
+		}
+		return normalizeValue(rv.Elem(), seen)
+
+	case reflect.Struct:
+		for _, f := range normalizeFields(rv.Type()) {
+			if f.names == nil {
+				if err := normalizeValue(rv.Field(f.index), seen); err != nil {
+					return err
+				}
+				continue
+			}
+			applyNormalizers(rv.Field(f.index), f.names)
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if err := normalizeValue(rv.Index(i), seen); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		elem := rv.Type().Elem()
+		if !mayNormalize(elem) {
+			return nil
+		}
+		for _, key := range rv.MapKeys() {
+			if elem.Kind() == reflect.Ptr || elem.Kind() == reflect.Interface {
+				if err := normalizeValue(rv.MapIndex(key), seen); err != nil {
+					return err
+				}
+				continue
+			}
+
+			// map values are not addressable, normalize a copy and set it back
+			cp := reflect.New(elem).Elem()
+			cp.Set(rv.MapIndex(key))
+			if err := normalizeValue(cp, seen); err != nil {
+				return err
+			}
+			rv.SetMapIndex(key, cp)
+		}
+	}
+
+	return nil
+}
+
+// mayNormalize reports whether the values of the type could have the
+// normalize tagged fields.
+func mayNormalize(t reflect.Type) bool {
+	return mayNormalizeType(t, make(map[reflect.Type]bool))
+}
+
+func mayNormalizeType(t reflect.Type, visiting map[reflect.Type]bool) bool {
+	if visiting[t] {
+		return false
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return mayNormalizeType(t.Elem(), visiting)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" && !f.Anonymous {
+				continue
+			}
+			if tag := f.Tag.Get(NormalizeTag); tag != "" && tag != "-" {
+				return true
+			}
+			if mayNormalizeType(f.Type, visiting) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// normalizeField is the exported field of the struct, names are the
+// normalizers of the tag, nil for the untagged fields which are walked.
+type normalizeField struct {
+	index int
+	names []string
+}
+
+// normalizeFieldsCache caches the fields of the struct types, the tags are
+// checked once, when the type is first seen.
+var normalizeFieldsCache sync.Map // map[reflect.Type][]normalizeField
+
+// normalizeFields returns the fields of the struct type. It panics on the
+// unknown normalizer or the tag on the non string field, they're the
+// programming errors.
+func normalizeFields(t reflect.Type) []normalizeField {
+	if fields, ok := normalizeFieldsCache.Load(t); ok {
+		return fields.([]normalizeField)
+	}
+
+	fields := make([]normalizeField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue // unexported
+		}
+
+		field := normalizeField{index: i}
+		if tag := f.Tag.Get(NormalizeTag); tag != "" && tag != "-" {
+			names, err := parseNormalizeTag(tag, f.Type)
+			if err != nil {
+				panic(fmt.Sprintf("api: normalize field %s.%s: %s", t, f.Name, err))
+			}
+			field.names = names
+		}
+		fields = append(fields, field)
+	}
+
+	normalizeFieldsCache.Store(t, fields)
+	return fields
+}
+
+// parseNormalizeTag returns the normalizer names of the tag of the field type
+func parseNormalizeTag(tag string, t reflect.Type) ([]string, error) {
+	if kind := stringElem(t).Kind(); kind != reflect.String {
+		return nil, fmt.Errorf("unsupported type %s, want the string, *string, []string or map[string]string", t)
+	}
+
+	names := make([]string, 0, strings.Count(tag, ",")+1)
+	for _, name := range strings.Split(tag, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := getNormalizer(name); !ok {
+			return nil, fmt.Errorf("unknown normalizer %q", name)
+		}
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// stringElem returns the element type of the supported field types
+func stringElem(t reflect.Type) reflect.Type {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return stringElem(t.Elem())
+	case reflect.Map:
+		return t.Elem()
+	}
+	return t
+}
+
+// applyNormalizers normalizes the tagged field value
+func applyNormalizers(rv reflect.Value, names []string) {
+	switch rv.Kind() {
+	case reflect.String:
+		if !rv.CanSet() {
+			return
+		}
+		s := rv.String()
+		for _, name := range names {
+			if fn, ok := getNormalizer(name); ok {
+				s = fn(s)
+			}
+		}
+		rv.SetString(s)
+
+	case reflect.Ptr:
+		if !rv.IsNil() {
+			applyNormalizers(rv.Elem(), names)
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			applyNormalizers(rv.Index(i), names)
+		}
+
+	case reflect.Map:
+		if rv.Type().Elem().Kind() != reflect.String {
+			return
+		}
+		for _, key := range rv.MapKeys() {
+			cp := reflect.New(rv.Type().Elem()).Elem()
+			cp.Set(rv.MapIndex(key))
+			applyNormalizers(cp, names)
+			rv.SetMapIndex(key, cp)
+		}
+	}
+}
+
+// collapseSpaces replaces all the consecutive white spaces with a single
+// space, the leading & trailing ones as well. Use it with trim to drop them.
+// Ex: "  a  b  " => " a b "
+func collapseSpaces(s string) string {
+	var (
+		b     strings.Builder
+		space bool
+	)
+	b.Grow(len(s))
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			if !space {
+				b.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		space = false
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// stripHTML removes the html tags, comments and the content of the script and
+// style elements from the free text.
+func stripHTML(s string) string {
+	if !strings.Contains(s, "<") {
+		return s
+	}
+
+	var (
+		b    strings.Builder
+		skip string // closing tag of the element whose content is dropped
+	)
+	b.Grow(len(s))
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '<')
+		if i < 0 {
+			if skip == "" {
+				b.WriteString(s)
+			}
+			break
+		}
+		if skip == "" {
+			b.WriteString(s[:i])
+		}
+		s = s[i:]
+
+		// not a tag. Ex: "a < b"
+		if len(s) == 1 || !isTagStart(s[1]) {
+			if skip == "" {
+				b.WriteByte('<')
+			}
+			s = s[1:]
+			continue
+		}
+
+		end := ">"
+		if strings.HasPrefix(s, "<!--") {
+			end = "-->"
+		}
+		j := strings.Index(s, end)
+		if j < 0 {
+			// unterminated, not a tag. Ex: "a <b", no tag follows without ">"
+			if end == ">" {
+				if skip == "" {
+					b.WriteString(s)
+				}
+				break
+			}
+			if skip == "" {
+				b.WriteByte('<')
+			}
+			s = s[1:]
+			continue
+		}
+		tag := strings.ToLower(s[:j+len(end)])
+		s = s[j+len(end):]
+
+		switch {
+		case skip != "":
+			if strings.HasPrefix(tag, skip) {
+				skip = ""
+			}
+		case strings.HasPrefix(tag, "<script"):
+			skip = "</script"
+		case strings.HasPrefix(tag, "<style"):
+			skip = "</style"
+		}
+	}
+
+	return b.String()
+}
+
+func isTagStart(c byte) bool {
+	return c == '/' || c == '!' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizers(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   Normalizer
+		in   string
+		want string
+	}{
+		{"trim", strings.TrimSpace, " \t a b \n", "a b"},
+		{"lower", strings.ToLower, "Gopher@Example.COM", "gopher@example.com"},
+		{"collapse", collapseSpaces, "  a \t\n b  ", " a b "},
+		{"collapse unicode", collapseSpaces, "a\u00a0\u2003b", "a b"},
+		{"strip tags", stripHTML, "<p>Hello <b>world</b></p>", "Hello world"},
+		{"strip comment", stripHTML, "a<!-- <b>x</b> -->b", "ab"},
+		{"strip script", stripHTML, "a<script>alert('<b>')</script>b<STYLE>p{}</style>c", "abc"},
+		{"not a tag", stripHTML, "a < b && 1<2", "a < b && 1<2"},
+		{"unterminated tag", stripHTML, "a <b and more", "a <b and more"},
+		{"unterminated after tag", stripHTML, "<i>x</i> <b and more", "x <b and more"},
+		{"unterminated comment", stripHTML, "a <!-- b <i>c</i>", "a <!-- b c"},
+		{"unterminated script", stripHTML, "a<script>alert(1)", "a"},
+		{"no html", stripHTML, "plain", "plain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.fn(tt.in); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+type nAddress struct {
+	City string `json:"city" normalize:"trim,collapse_spaces"`
+}
+
+type nUser struct {
+	Email   string            `json:"email" normalize:"trim,lower"`
+	Name    *string           `json:"name" normalize:"collapse_spaces,trim"`
+	Tags    []string          `json:"tags" normalize:"lower"`
+	Labels  map[string]string `json:"labels" normalize:"trim"`
+	Bio     string            `json:"bio" normalize:"strip_html,trim"`
+	Raw     string            `json:"raw"`
+	Address nAddress          `json:"address"`
+	Others  []*nAddress       `json:"others"`
+}
+
+func TestNormalize(t *testing.T) {
+	name := "  Go   pher "
+	u := &nUser{
+		Email:   " Gopher@Example.COM ",
+		Name:    &name,
+		Tags:    []string{"A", "b"},
+		Labels:  map[string]string{"k": " v "},
+		Bio:     " <b>hi</b> ",
+		Raw:     " raw ",
+		Address: nAddress{City: " New   York "},
+		Others:  []*nAddress{nil, {City: " a  b"}},
+	}
+	if err := Normalize(u); err != nil {
+		t.Fatal(err)
+	}
+
+	want := &nUser{
+		Email:   "gopher@example.com",
+		Tags:    []string{"a", "b"},
+		Labels:  map[string]string{"k": "v"},
+		Bio:     "hi",
+		Raw:     " raw ",
+		Address: nAddress{City: "New York"},
+		Others:  []*nAddress{nil, {City: "a b"}},
+	}
+	if *u.Name != "Go pher" {
+		t.Errorf("name = %q, want %q", *u.Name, "Go pher")
+	}
+	u.Name = nil
+	if !reflect.DeepEqual(u, want) {
+		t.Errorf("Normalize() = %+v, want %+v", u, want)
+	}
+}
+
+func TestNormalizeInvalidTag(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{"unknown normalizer", &struct {
+			Email string `normalize:"trim,lowr"`
+		}{}, `unknown normalizer "lowr"`},
+		{"struct field", &struct {
+			Address nAddress `normalize:"trim"`
+		}{}, "unsupported type api.nAddress"},
+		{"int field", &struct {
+			Age []int `normalize:"trim"`
+		}{}, "unsupported type []int"},
+		{"nested", &struct {
+			Items []struct {
+				Name string `normalize:"trm"`
+			}
+		}{Items: make([]struct {
+			Name string `normalize:"trm"`
+		}, 1)}, `unknown normalizer "trm"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				rvr := recover()
+				if msg, _ := rvr.(string); !strings.Contains(msg, tt.want) {
+					t.Errorf("panic = %v, want %q", rvr, tt.want)
+				}
+			}()
+			Normalize(tt.v)
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/schema"
+	"github.com/manigandand/adk/errors"
+	"github.com/manigandand/adk/middleware"
+)
+
+// RouteResolver reads the path params and the matched route of the router
+// which served the request. chi, gorilla/mux & http.ServeMux (built with
+// go 1.23+, which sets r.Pattern) resolvers are registered by default.
+type RouteResolver interface {
+	// PathParam returns the value of the path param, ok is false if the
+	// request is not routed by the router or the param is not found.
+	PathParam(r *http.Request, name string) (value string, ok bool)
+	// RoutePattern returns the route template. Ex: /user/{id}
+	RoutePattern(r *http.Request) string
+	// RouteName returns the name of the route, if the router supports names.
+	RouteName(r *http.Request) string
+}
+
+var (
+	resolversMu sync.RWMutex
+	resolvers   = []RouteResolver{
+		chiResolver{},
+		gorillaResolver{},
+	}
+)
+
+// RegisterRouteResolver registers the route resolver of the custom router.
+// The resolvers registered later takes the precedence.
+func RegisterRouteResolver(resolver RouteResolver) {
+	resolversMu.Lock()
+	defer resolversMu.Unlock()
+
+	resolvers = append([]RouteResolver{resolver}, resolvers...)
+}
+
+func getResolvers() []RouteResolver {
+	resolversMu.RLock()
+	defer resolversMu.RUnlock()
+
+	return resolvers
+}
+
+// PathParam returns the value of the path param, whichever router served the request.
+//
+// EX:
+//
+//	r.Method(http.MethodGet, "/user/{id}", api.Handler(GetUserHandler))
+//	id := api.PathParam(r, "id")
+func PathParam(r *http.Request, name string) string {
+	for _, resolver := range getResolvers() {
+		if value, ok := resolver.PathParam(r, name); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// RoutePattern returns the route template matched by the router. Ex: /user/{id}
+// Use it after the next handler is called in the middlewares, the routers
+// match the route while serving the request.
+func RoutePattern(r *http.Request) string {
+	for _, resolver := range getResolvers() {
+		if pattern := resolver.RoutePattern(r); pattern != "" {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// RouteName returns the name of the matched route, if the router supports names.
+func RouteName(r *http.Request) string {
+	for _, resolver := range getResolvers() {
+		if name := resolver.RouteName(r); name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// pathDecoder decodes the path params into the struct fields tagged with path.
+// shares the custom converters with the FormDecoder.
+var pathDecoder *schema.Decoder
+
+// DecodePath decodes the path params into the struct fields tagged with path,
+// applies the normalize tags and invokes the custom validator interfaces.
+//
+// EX:
+//
+//	type getUserReq struct {
+//		OrgID uint               `path:"org_id"`
+//		ID    primitive.ObjectID `path:"id"`
+//	}
+func DecodePath(r *http.Request, v interface{}) *errors.AppError {
+	params := url.Values{}
+	for _, name := range pathTagNames(v) {
+		if value := PathParam(r, name); value != "" {
+			params.Set(name, value)
+		}
+	}
+
+	if err := pathDecoder.Decode(v, params); err != nil {
+		return schemaError(err, params, "invalid path params")
+	}
+
+	if err := Normalize(v); err != nil {
+		return err
+	}
+	return Validate(r.Context(), v)
+}
+
+// pathTagNames returns the path tag names of the struct fields of v
+func pathTagNames(v interface{}) []string {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Tag.Get("path") == "" {
+			names = append(names, pathTagNames(reflect.New(f.Type).Interface())...)
+			continue
+		}
+		if name := strings.Split(f.Tag.Get("path"), ",")[0]; name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// chi --------------------------------------------------------------------------
+
+type chiResolver struct{}
+
+func (chiResolver) PathParam(r *http.Request, name string) (string, bool) {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return "", false
+	}
+
+	for i := len(rctx.URLParams.Keys) - 1; i >= 0; i-- {
+		if rctx.URLParams.Keys[i] == name {
+			return rctx.URLParams.Values[i], true
+		}
+	}
+	return "", false
+}
+
+func (chiResolver) RoutePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		return rctx.RoutePattern()
+	}
+	return ""
+}
+
+func (chiResolver) RouteName(r *http.Request) string {
+	return ""
+}
+
+// gorilla/mux ------------------------------------------------------------------
+
+type gorillaResolver struct{}
+
+func (gorillaResolver) PathParam(r *http.Request, name string) (string, bool) {
+	value, ok := mux.Vars(r)[name]
+	return value, ok
+}
+
+func (gorillaResolver) RoutePattern(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return ""
+}
+
+func (gorillaResolver) RouteName(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		return route.GetName()
+	}
+	return ""
+}
+
+// init the path decoder and the logger route pattern
+func init() {
+	pathDecoder = schema.NewDecoder()
+	pathDecoder.SetAliasTag("path")
+	pathDecoder.ZeroEmpty(true)
+	pathDecoder.IgnoreUnknownKeys(true)
+	registerConverters(pathDecoder)
+
+	middleware.RoutePattern = RoutePattern
+}
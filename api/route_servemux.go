@@ -0,0 +1,32 @@
+//go:build go1.23
+
+package api
+
+import "net/http"
+
+// http.ServeMux ----------------------------------------------------------------
+
+// the ServeMux resolver needs r.Pattern of go 1.23, the module supports the
+// older go versions without it
+func init() {
+	resolvers = append(resolvers, serveMuxResolver{})
+}
+
+type serveMuxResolver struct{}
+
+func (serveMuxResolver) PathParam(r *http.Request, name string) (string, bool) {
+	if r.Pattern == "" {
+		return "", false
+	}
+
+	value := r.PathValue(name)
+	return value, value != ""
+}
+
+func (serveMuxResolver) RoutePattern(r *http.Request) string {
+	return r.Pattern
+}
+
+func (serveMuxResolver) RouteName(r *http.Request) string {
+	return ""
+}
@@ -0,0 +1,230 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/manigandand/adk/errors"
+)
+
+// Static asset cache headers, exported so that it can be changed by developers
+var (
+	// StaticImmutableCache is the Cache-Control of the fingerprinted assets
+	StaticImmutableCache = "public, max-age=31536000, immutable"
+	// StaticRevalidateCache is the Cache-Control of the other assets & index.html,
+	// the browsers revalidate them with the ETag.
+	StaticRevalidateCache = "no-cache"
+)
+
+// fingerprintPattern matches the content hash in the file names built by the
+// bundlers. Ex: app.3f9a1c2b.js, index-BdK3f9_a.js
+var fingerprintPattern = regexp.MustCompile(`[.-]([0-9A-Za-z_]{8,})\.[0-9A-Za-z]+$`)
+
+// IsFingerprinted reports whether the file name carries the content hash, the
+// fingerprinted assets are cached forever. Exported so that it can be changed
+// by developers for the custom naming.
+var IsFingerprinted = func(name string) bool {
+	m := fingerprintPattern.FindStringSubmatch(path.Base(name))
+	// the hash has at least a digit, so words like "-component.js" are skipped
+	return m != nil && strings.ContainsAny(m[1], "0123456789")
+}
+
+// Static serves the static assets of the embed.FS or the directory. The files
+// are served with the strong ETag of the content hash and the precompressed
+// ".gz" variant is served to the clients which accept gzip.
+//
+// EX:
+//
+//	//go:embed dist
+//	var dist embed.FS
+//
+//	ui, _ := fs.Sub(dist, "dist")
+//	static := api.NewStatic(ui)
+//	static.SPA = true
+//	static.APIPrefixes = []string{"/v1/"}
+//	r.Handle("/*", static)
+type Static struct {
+	FS fs.FS
+	// Index is the file served for the directories & the SPA fallback
+	Index string
+	// SPA serves the index for the unknown paths, so the client side router
+	// can handle them. The missing files with an extension are still 404.
+	SPA bool
+	// APIPrefixes are the path prefixes of the api routes, the missing paths
+	// under them are responded with the JSON 404 app error via respond.Fail.
+	APIPrefixes []string
+
+	etags sync.Map // name -> staticETag
+}
+
+type staticETag struct {
+	etag    string
+	size    int64
+	modTime time.Time
+}
+
+// NewStatic returns the static handler of the file system
+func NewStatic(fsys fs.FS) *Static {
+	return &Static{
+		FS:    fsys,
+		Index: "index.html",
+	}
+}
+
+// StaticDir returns the static handler of the directory
+func StaticDir(dir string) *Static {
+	return NewStatic(os.DirFS(dir))
+}
+
+// ServeHTTP implements http handler interface
+func (s *Static) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	Handler(s.serve).ServeHTTP(w, r)
+}
+
+func (s *Static) serve(w http.ResponseWriter, r *http.Request) *errors.AppError {
+	urlPath := path.Clean("/" + r.URL.Path)
+	if s.isAPIPath(urlPath) {
+		return errors.NotFound("route not found")
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		return errors.NewAppError(http.StatusMethodNotAllowed, "method not allowed")
+	}
+
+	name := strings.TrimPrefix(urlPath, "/")
+	if name == "" {
+		name = "."
+	}
+	if info, err := fs.Stat(s.FS, name); err == nil && info.IsDir() {
+		name = path.Join(name, s.Index)
+	}
+
+	if s.exists(name) {
+		return s.serveFile(w, r, name)
+	}
+	if s.SPA && path.Ext(urlPath) == "" && s.exists(s.Index) {
+		return s.serveFile(w, r, s.Index)
+	}
+
+	http.NotFound(w, r)
+	return nil
+}
+
+func (s *Static) isAPIPath(urlPath string) bool {
+	for _, prefix := range s.APIPrefixes {
+		if strings.HasPrefix(urlPath+"/", strings.TrimSuffix(prefix, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Static) exists(name string) bool {
+	info, err := fs.Stat(s.FS, name)
+	return err == nil && !info.IsDir()
+}
+
+// serveFile serves the file or its ".gz" variant. http.ServeContent handles
+// the conditional & range requests.
+func (s *Static) serveFile(w http.ResponseWriter, r *http.Request, name string) *errors.AppError {
+	servedName := name
+	if s.exists(name + ".gz") {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if acceptsGzip(r) {
+			servedName = name + ".gz"
+			w.Header().Set("Content-Encoding", "gzip")
+		}
+	}
+
+	f, err := s.FS.Open(servedName)
+	if err != nil {
+		return errors.InternalServer("couldn't open the static file").AddDebug(err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return errors.InternalServer("couldn't read the static file").AddDebug(err)
+	}
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return errors.InternalServer("couldn't read the static file").AddDebug(err)
+		}
+		content = bytes.NewReader(data)
+	}
+
+	etag, appErr := s.etag(servedName, info, content)
+	if appErr != nil {
+		return appErr
+	}
+
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.Header().Set("ETag", etag)
+	if name != s.Index && IsFingerprinted(name) {
+		w.Header().Set("Cache-Control", StaticImmutableCache)
+	} else {
+		w.Header().Set("Cache-Control", StaticRevalidateCache)
+	}
+
+	http.ServeContent(w, r, name, info.ModTime(), content)
+	return nil
+}
+
+// etag returns the strong ETag of the content hash, the hash is cached until
+// the size or the modified time of the file changes.
+func (s *Static) etag(name string, info fs.FileInfo, content io.ReadSeeker) (string, *errors.AppError) {
+	if v, ok := s.etags.Load(name); ok {
+		cached := v.(staticETag)
+		if cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+			return cached.etag, nil
+		}
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, content); err != nil {
+		return "", errors.InternalServer("couldn't read the static file").AddDebug(err)
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", errors.InternalServer("couldn't read the static file").AddDebug(err)
+	}
+
+	etag := `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+	s.etags.Store(name, staticETag{
+		etag:    etag,
+		size:    info.Size(),
+		modTime: info.ModTime(),
+	})
+	return etag, nil
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		enc = strings.TrimSpace(enc)
+		if name, q, ok := strings.Cut(enc, ";"); ok {
+			if strings.TrimSpace(name) == "gzip" && strings.ReplaceAll(strings.TrimSpace(q), " ", "") != "q=0" {
+				return true
+			}
+			continue
+		}
+		if enc == "gzip" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,261 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/manigandand/adk/errors"
+)
+
+// ContextValidator is the context aware custom validator interface. Use this
+// when the validation needs the request context. Ex: DB uniqueness checks.
+//
+// EX:
+//
+//	func (c *createUserReq) ValidateContext(ctx context.Context) *errors.AppError {
+//		if store.EmailExists(ctx, c.Email) {
+//			return errors.Conflict("email already exists").WithField("email")
+//		}
+//		return nil
+//	}
+type ContextValidator interface {
+	ValidateContext(ctx context.Context) *errors.AppError
+}
+
+var (
+	validatorType        = reflect.TypeOf((*ok)(nil)).Elem()
+	contextValidatorType = reflect.TypeOf((*ContextValidator)(nil)).Elem()
+)
+
+// Validate runs the Validate() and ValidateContext(ctx) methods of v and of all
+// the nested structs, slice/array elements and map values of v. The parent is
+// validated before its children and the first error is returned.
+// The field of the returned error is prefixed with the path of the nested value.
+// Ex: items[3].sku, attributes[color]
+// The validators of the embedded structs are called even if the parent shadows
+// them with its own. The promoted ones are called on both the parent and the
+// embedded value, so the validators must not have side effects.
+func Validate(ctx context.Context, v interface{}) *errors.AppError {
+	w := &validationWalker{
+		ctx:  ctx,
+		seen: make(map[uintptr]bool),
+	}
+	return w.walk(reflect.ValueOf(v), "")
+}
+
+type validationWalker struct {
+	ctx  context.Context
+	seen map[uintptr]bool
+}
+
+func (w *validationWalker) walk(rv reflect.Value, path string) *errors.AppError {
+	if !rv.IsValid() || !mayValidate(rv.Type()) {
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+		return w.walk(rv.Elem(), path)
+
+	case reflect.Ptr:
+		if rv.IsNil() || w.seen[rv.Pointer()] {
+			return nil
+		}
+		w.seen[rv.Pointer()] = true
+
+		// pointer method set includes the value receiver methods as well
+		if err := w.validate(rv.Interface(), path); err != nil {
+			return err
+		}
+		return w.walkChildren(rv.Elem(), path)
+	}
+
+	// map values are not addressable, copy them to reach the pointer receiver methods
+	if !rv.CanAddr() {
+		cp := reflect.New(rv.Type()).Elem()
+		cp.Set(rv)
+		rv = cp
+	}
+	if err := w.validate(rv.Addr().Interface(), path); err != nil {
+		return err
+	}
+	return w.walkChildren(rv, path)
+}
+
+func (w *validationWalker) walkChildren(rv reflect.Value, path string) *errors.AppError {
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return w.walk(rv, path)
+
+	case reflect.Struct:
+		t := rv.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" && !f.Anonymous {
+				continue // unexported
+			}
+
+			name, skip := jsonFieldName(f)
+			if skip {
+				continue
+			}
+			if f.Anonymous && name == "" {
+				if err := w.walkEmbedded(rv.Field(i), path); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := w.walk(rv.Field(i), joinFieldPath(path, name)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if err := w.walk(rv.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, key := range keys {
+			p := fmt.Sprintf("%s[%v]", path, key.Interface())
+			if err := w.walk(rv.MapIndex(key), p); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// walkEmbedded validates the embedded value & its children. The validators
+// of the embedded value are called even if the parent has them promoted, as
+// the parent may shadow them with its own, which can't be told apart by
+// reflection. So the promoted validators are called twice, once on the
+// parent and once on the embedded value.
+func (w *validationWalker) walkEmbedded(rv reflect.Value, path string) *errors.AppError {
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+
+	// the unexported embedded values can't be called by reflection
+	if rv.CanAddr() && rv.CanInterface() {
+		if err := w.validate(rv.Addr().Interface(), path); err != nil {
+			return err
+		}
+	}
+
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	return w.walkChildren(rv, path)
+}
+
+// validate calls the custom validator methods of v
+func (w *validationWalker) validate(v interface{}, path string) *errors.AppError {
+	if payload, ok := v.(ok); ok {
+		if err := payload.Validate(); err != nil {
+			return err.PrefixField(path)
+		}
+	}
+	if payload, ok := v.(ContextValidator); ok {
+		if err := payload.ValidateContext(w.ctx); err != nil {
+			return err.PrefixField(path)
+		}
+	}
+
+	return nil
+}
+
+// mayValidateCache caches mayValidate per type
+var mayValidateCache sync.Map // map[reflect.Type]bool
+
+// mayValidate reports whether the type or any of its nested types could
+// implement one of the validator interfaces.
+func mayValidate(t reflect.Type) bool {
+	if may, ok := mayValidateCache.Load(t); ok {
+		return may.(bool)
+	}
+
+	may := mayValidateType(t, make(map[reflect.Type]bool))
+	mayValidateCache.Store(t, may)
+	return may
+}
+
+func mayValidateType(t reflect.Type, visiting map[reflect.Type]bool) bool {
+	if visiting[t] {
+		return false
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	if implementsValidator(t) || implementsValidator(reflect.PointerTo(t)) {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return mayValidateType(t.Elem(), visiting)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" && !f.Anonymous {
+				continue
+			}
+			if mayValidateType(f.Type, visiting) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func implementsValidator(t reflect.Type) bool {
+	return t.Implements(validatorType) || t.Implements(contextValidatorType)
+}
+
+// jsonFieldName returns the json name of the struct field. Embedded structs
+// without the json name returns empty name, since their fields are promoted.
+func jsonFieldName(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", true
+	}
+
+	name := strings.Split(tag, ",")[0]
+	if name != "" {
+		return name, false
+	}
+	if f.Anonymous {
+		return "", false
+	}
+	return f.Name, false
+}
+
+func joinFieldPath(path, name string) string {
+	switch {
+	case name == "":
+		return path
+	case path == "":
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/manigandand/adk/errors"
+)
+
+type vItem struct {
+	SKU string `json:"sku"`
+	Qty int    `json:"qty"`
+}
+
+func (i *vItem) Validate() *errors.AppError {
+	if i.SKU == "" {
+		return errors.KeyRequired("sku").WithField("sku")
+	}
+	return nil
+}
+
+// errNegativeQty is shared, the prefixed field must not leak into it
+var errNegativeQty = errors.BadRequest("qty must be positive").WithField("qty")
+
+type vQty struct {
+	Qty int `json:"qty"`
+}
+
+func (q vQty) Validate() *errors.AppError {
+	if q.Qty < 0 {
+		return errNegativeQty
+	}
+	return nil
+}
+
+type vOrder struct {
+	ID     string           `json:"id"`
+	Items  []vItem          `json:"items"`
+	Ptrs   []*vItem         `json:"ptrs"`
+	Attrs  map[string]vItem `json:"attrs"`
+	Lines  [2]vQty          `json:"lines"`
+	Nested *vOrder          `json:"nested"`
+	Skip   vItem            `json:"-"`
+}
+
+func (o *vOrder) Validate() *errors.AppError {
+	if o.ID == "bad" {
+		return errors.BadRequest("invalid id").WithField("id")
+	}
+	return nil
+}
+
+// VService is exported, the unexported embedded values can't be called by reflection
+type VService struct {
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+}
+
+func (s *VService) Validate() *errors.AppError {
+	if (s.CertFile == "") != (s.KeyFile == "") {
+		return errors.BadRequest("cert & key must be set together").WithField("cert_file")
+	}
+	return nil
+}
+
+// vConfig shadows the Validate of the embedded VService
+type vConfig struct {
+	VService
+	Name string `json:"name"`
+}
+
+func (c *vConfig) Validate() *errors.AppError {
+	if c.Name == "" {
+		return errors.KeyRequired("name")
+	}
+	return nil
+}
+
+// vPromoted has the Validate of the embedded VService promoted
+type vPromoted struct {
+	*VService
+	Name string `json:"name"`
+}
+
+type vNamed struct {
+	Service VService `json:"service"`
+	Config  vConfig  `json:"config"`
+}
+
+type vCtx struct {
+	Email string `json:"email"`
+}
+
+type ctxTakenKey struct{}
+
+func (c *vCtx) ValidateContext(ctx context.Context) *errors.AppError {
+	if taken, _ := ctx.Value(ctxTakenKey{}).(string); taken == c.Email {
+		return errors.Conflict("email already exists").WithField("email")
+	}
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		v         interface{}
+		wantMsg   string
+		wantField string
+	}{
+		{"valid", &vOrder{ID: "1", Items: []vItem{{SKU: "a"}}}, "", ""},
+		{"nil", nil, "", ""},
+		{"nil pointer", (*vOrder)(nil), "", ""},
+		{"parent first", &vOrder{ID: "bad", Items: []vItem{{}}}, "invalid id", "id"},
+		{"slice", &vOrder{Items: []vItem{{SKU: "a"}, {}}}, "sku is required", "items[1].sku"},
+		{"slice of pointers", &vOrder{Ptrs: []*vItem{nil, {}}}, "sku is required", "ptrs[1].sku"},
+		{"map", &vOrder{Attrs: map[string]vItem{"b": {SKU: "b"}, "a": {}}}, "sku is required", "attrs[a].sku"},
+		{"array of value receivers", &vOrder{Lines: [2]vQty{{1}, {-1}}}, "qty must be positive", "lines[1].qty"},
+		{"nested struct", &vOrder{Nested: &vOrder{Items: []vItem{{}}}}, "sku is required", "nested.items[0].sku"},
+		{"json skipped field", &vOrder{Skip: vItem{}}, "", ""},
+		{"map value", map[string]*vItem{"x": {}}, "sku is required", "[x].sku"},
+		{"shadowed embedded", &vConfig{VService: VService{CertFile: "c"}, Name: "n"}, "cert & key must be set together", "cert_file"},
+		{"shadowing parent", &vConfig{}, "name is required", "name"},
+		{"promoted embedded", &vPromoted{VService: &VService{KeyFile: "k"}}, "cert & key must be set together", "cert_file"},
+		{"named fields", &vNamed{Config: vConfig{Name: "n", VService: VService{KeyFile: "k"}}}, "cert & key must be set together", "config.cert_file"},
+		{"context validator", &struct {
+			Users []vCtx `json:"users"`
+		}{Users: []vCtx{{Email: "a"}, {Email: "taken"}}}, "email already exists", "users[1].email"},
+	}
+	ctx := context.WithValue(context.Background(), ctxTakenKey{}, "taken")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(ctx, tt.v)
+			if tt.wantMsg == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v (%s), want nil", err, err.GetField())
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() = nil, want %q", tt.wantMsg)
+			}
+			if err.Error() != tt.wantMsg || err.GetField() != tt.wantField {
+				t.Errorf("Validate() = %q (%s), want %q (%s)", err.Error(), err.GetField(), tt.wantMsg, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestValidateCycle(t *testing.T) {
+	o := &vOrder{ID: "1"}
+	o.Nested = o
+	if err := Validate(context.Background(), o); err != nil {
+		t.Fatalf("Validate() = %v, want nil", err)
+	}
+}
+
+func TestValidateSharedError(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v := &vOrder{Nested: &vOrder{Lines: [2]vQty{{-1}}}}
+			if err := Validate(context.Background(), v); err.GetField() != "nested.lines[0].qty" {
+				t.Errorf("field = %s, want nested.lines[0].qty", err.GetField())
+			}
+		}()
+	}
+	wg.Wait()
+
+	if field := errNegativeQty.GetField(); field != "qty" {
+		t.Errorf("shared error field = %s, want qty", field)
+	}
+}
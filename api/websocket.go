@@ -0,0 +1,303 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+	"github.com/manigandand/adk/errors"
+	"github.com/manigandand/adk/middleware"
+	"github.com/manigandand/adk/respond"
+)
+
+// WebSocket limits, exported so that it can be changed by developers
+var (
+	// WSPingInterval is the interval of the keepalive pings
+	WSPingInterval = 30 * time.Second
+	// WSPongWait is the time allowed to read the next pong/message from the client
+	WSPongWait = 60 * time.Second
+	// WSWriteWait is the time allowed to write a message to the client
+	WSWriteWait = 10 * time.Second
+	// WSMaxMessageSize is the max size of the message read from the client
+	WSMaxMessageSize int64 = 1 << 20
+	// WSSendBuffer is the max number of messages queued to the client, the slow
+	// clients are disconnected once the buffer is full
+	WSSendBuffer = 64
+)
+
+// WSUpgrader is the websocket upgrader used by WebSocket. Set CheckOrigin to
+// allow the cross origin connections.
+var WSUpgrader = &websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	Error: func(w http.ResponseWriter, r *http.Request, status int, reason error) {
+		respond.Fail(w, errors.NewAppError(status, reason.Error()).AddDebug(reason))
+	},
+}
+
+// WSHandler handles the websocket connection. The connection is closed once
+// the handler returns, the returned app error is mapped to the close code and
+// reason. see WSCloseCode.
+type WSHandler func(ctx context.Context, conn *WSConn) *errors.AppError
+
+// WebSocket upgrades the request to the RFC 6455 websocket connection and runs
+// the handler. The errors before the upgrade are sent as the JSON app error.
+// The connection context carries the request context values, Ex: request id.
+// It is cancelled when a read or a write fails, so a handler which doesn't
+// read notices the client disconnect only by the next failing keepalive
+// ping, within WSPingInterval + WSWriteWait.
+//
+// EX:
+//
+//	r.Method(http.MethodGet, "/ws/chat", api.WebSocket(ChatHandler))
+//
+//	func ChatHandler(ctx context.Context, conn *api.WSConn) *errors.AppError {
+//		return api.ReadLoop(conn, func(ctx context.Context, msg *chatMsg) *errors.AppError {
+//			return conn.WriteJSON(msg)
+//		})
+//	}
+func WebSocket(fn WSHandler) Handler {
+	return func(w http.ResponseWriter, r *http.Request) *errors.AppError {
+		ws, err := WSUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// the upgrader has already responded with the error
+			return nil
+		}
+
+		conn := newWSConn(r.Context(), ws)
+		go conn.writeLoop()
+
+		appErr := runWSHandler(fn, conn)
+		if appErr != nil {
+			appErr.Log()
+		}
+		conn.close(appErr)
+		<-conn.stopped
+		return nil
+	}
+}
+
+// runWSHandler recovers the panic of the handler, the connection is closed
+// with the internal error.
+func runWSHandler(fn WSHandler, conn *WSConn) (err *errors.AppError) {
+	defer func() {
+		if rvr := recover(); rvr != nil {
+			middleware.LogPanic(rvr, "websocket handler")
+			err = errors.InternalServerStd().WithNoLog()
+		}
+	}()
+
+	return fn(conn.ctx, conn)
+}
+
+// WSCloseCode maps the app error to the websocket close code
+//
+//	nil     -> 1000 normal closure
+//	429     -> 1013 try again later
+//	460     -> 1001 going away, client cancelled
+//	4XX     -> 4000 + status. Ex: 4401, 4403, 4404
+//	5XX     -> 1011 internal error
+func WSCloseCode(err *errors.AppError) int {
+	if err == nil {
+		return websocket.CloseNormalClosure
+	}
+
+	status := err.GetStatus()
+	switch {
+	case status == http.StatusTooManyRequests:
+		return websocket.CloseTryAgainLater
+	case status == statusClientCancelled:
+		return websocket.CloseGoingAway
+	case status >= 400 && status < 500:
+		return 4000 + status
+	}
+	return websocket.CloseInternalServerErr
+}
+
+// statusClientCancelled is the status of the cancelled requests. see errors.OverwriteStatusCode
+const statusClientCancelled = 460
+
+// maxCloseReason is the max length of the close reason, control frame
+// payload is limited to 125 bytes including the 2 bytes close code.
+const maxCloseReason = 123
+
+// WSConn is the websocket connection. Reads must be done from a single
+// goroutine, writes are safe to be called concurrently.
+type WSConn struct {
+	ws     *websocket.Conn
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	send    chan []byte
+	closing chan []byte // close frame
+	done    chan struct{}
+	stopped chan struct{} // write loop is stopped
+
+	closeOnce sync.Once
+}
+
+func newWSConn(ctx context.Context, ws *websocket.Conn) *WSConn {
+	ctx, cancel := context.WithCancel(ctx)
+	conn := &WSConn{
+		ws:      ws,
+		ctx:     ctx,
+		cancel:  cancel,
+		send:    make(chan []byte, WSSendBuffer),
+		closing: make(chan []byte, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	ws.SetReadLimit(WSMaxMessageSize)
+	_ = ws.SetReadDeadline(time.Now().Add(WSPongWait))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(WSPongWait))
+	})
+	return conn
+}
+
+// Context returns the connection context, it is cancelled when the connection
+// is closed or a read or a write fails.
+func (c *WSConn) Context() context.Context {
+	return c.ctx
+}
+
+// ReadJSON reads the next message from the client and decodes it into v using
+// DecodeJSON, so the normalize tags and the custom validators are applied.
+// The read errors cancels the connection context.
+func (c *WSConn) ReadJSON(v interface{}) *errors.AppError {
+	_, data, err := c.ws.ReadMessage()
+	if err != nil {
+		c.cancel()
+		if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+			return errors.NewAppError(statusClientCancelled, "websocket closed by the client").
+				AddDebug(err).WithNoLog()
+		}
+		if errors.Is(err, websocket.ErrReadLimit) {
+			return errors.NewAppError(http.StatusRequestEntityTooLarge, "websocket message is too large").
+				AddDebug(err)
+		}
+		return errors.NewAppError(statusClientCancelled, "websocket read failed").AddDebug(err)
+	}
+
+	return DecodeJSON(c.ctx, data, v)
+}
+
+// WriteJSON queues the message to be sent to the client. The slow clients,
+// whose send buffer is full, are disconnected with 1013 try again later.
+func (c *WSConn) WriteJSON(v interface{}) *errors.AppError {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return errors.InternalServer("couldn't encode websocket message").AddDebug(err)
+	}
+
+	select {
+	case <-c.done:
+		return errors.NewAppError(statusClientCancelled, "websocket connection is closed")
+	case c.send <- data:
+		return nil
+	default:
+		err := errors.TooManyRequests("websocket send buffer is full")
+		c.close(err)
+		return err
+	}
+}
+
+// ReadLoop reads the messages of type T until the client disconnects or fn
+// returns an error. The decode & validation errors of the messages are sent
+// to the client as the JSON app error and the loop continues.
+func ReadLoop[T any](conn *WSConn, fn func(ctx context.Context, msg *T) *errors.AppError) *errors.AppError {
+	for {
+		msg := new(T)
+		if err := conn.ReadJSON(msg); err != nil {
+			switch err.GetStatus() {
+			case statusClientCancelled:
+				// client is gone, nothing to close
+				return nil
+			case http.StatusBadRequest, http.StatusUnprocessableEntity:
+				if wErr := conn.WriteJSON(err); wErr != nil {
+					return wErr
+				}
+				continue
+			}
+			return err
+		}
+
+		if err := fn(conn.ctx, msg); err != nil {
+			return err
+		}
+	}
+}
+
+// writeLoop writes the queued messages and the keepalive pings
+func (c *WSConn) writeLoop() {
+	ticker := time.NewTicker(WSPingInterval)
+	defer func() {
+		ticker.Stop()
+		c.ws.Close()
+		close(c.stopped)
+	}()
+
+	for {
+		select {
+		case data := <-c.send:
+			if err := c.write(websocket.TextMessage, data); err != nil {
+				c.cancel()
+				return
+			}
+
+		case <-ticker.C:
+			if err := c.write(websocket.PingMessage, nil); err != nil {
+				c.cancel()
+				return
+			}
+
+		case frame := <-c.closing:
+			// flush the queued messages before the close frame
+		flush:
+			for {
+				select {
+				case data := <-c.send:
+					if err := c.write(websocket.TextMessage, data); err != nil {
+						return
+					}
+				default:
+					break flush
+				}
+			}
+			_ = c.write(websocket.CloseMessage, frame)
+			return
+		}
+	}
+}
+
+func (c *WSConn) write(messageType int, data []byte) error {
+	_ = c.ws.SetWriteDeadline(time.Now().Add(WSWriteWait))
+	return c.ws.WriteMessage(messageType, data)
+}
+
+// close sends the close frame of the app error and cancels the connection context
+func (c *WSConn) close(err *errors.AppError) {
+	c.closeOnce.Do(func() {
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		}
+		if len(reason) > maxCloseReason {
+			// cut on the rune boundary, the reason must be valid utf-8
+			i := maxCloseReason
+			for i > 0 && !utf8.RuneStart(reason[i]) {
+				i--
+			}
+			reason = reason[:i]
+		}
+
+		c.closing <- websocket.FormatCloseMessage(WSCloseCode(err), reason)
+		close(c.done)
+		c.cancel()
+	})
+}
@@ -0,0 +1,298 @@
+package apitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/manigandand/adk/api"
+	"github.com/manigandand/adk/errors"
+	"github.com/manigandand/adk/respond"
+	log "github.com/sirupsen/logrus"
+)
+
+type ctxKey string
+
+// echoHandler responds with the parts of the request set by the builder
+func echoHandler(w http.ResponseWriter, r *http.Request) *errors.AppError {
+	if r.URL.Query().Get("fail") != "" {
+		return errors.KeyRequired("email")
+	}
+
+	var body map[string]interface{}
+	if r.Body != http.NoBody && r.ContentLength != 0 {
+		if err := api.Decode(r, &body); err != nil {
+			return err
+		}
+	}
+	return respond.OK(w, map[string]interface{}{
+		"method":       r.Method,
+		"path":         r.URL.Path,
+		"query":        r.URL.Query().Get("q"),
+		"header":       r.Header.Get("X-Test"),
+		"content_type": r.Header.Get("Content-Type"),
+		"id":           api.PathParam(r, "id"),
+		"ctx":          r.Context().Value(ctxKey("org")),
+		"body":         body,
+		"items": []map[string]interface{}{
+			{"id": 1, "created_at": "2024-06-01T10:00:00Z"},
+			{"id": 2, "created_at": "2024-06-02T10:00:00Z"},
+		},
+	})
+}
+
+// recordingT records the failures of the assertions, Fatalf stops the
+// assertion by the panic which is recovered by run.
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+type fatal struct{}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func (t *recordingT) Fatalf(format string, args ...interface{}) {
+	t.Errorf(format, args...)
+	panic(fatal{})
+}
+
+func (t *recordingT) run(fn func(t testing.TB)) {
+	defer func() {
+		if rvr := recover(); rvr != nil {
+			if _, ok := rvr.(fatal); !ok {
+				panic(rvr)
+			}
+		}
+	}()
+	fn(t)
+}
+
+// failures runs the assertions against the recording T and returns the failures
+func failures(fn func(t testing.TB)) []string {
+	rt := &recordingT{}
+	rt.run(fn)
+	return rt.errors
+}
+
+func TestRequestBuilder(t *testing.T) {
+	New(t, api.Handler(echoHandler)).
+		Post("/user/7").
+		Query("q", "search").
+		Header("X-Test", "yes").
+		PathParam("id", "7").
+		ContextValue(ctxKey("org"), "acme").
+		JSON(map[string]interface{}{"email": "gopher@example.com"}).
+		Do().
+		Status(http.StatusOK).
+		Header("Content-Type", "application/json").
+		JSONPath("method", http.MethodPost).
+		JSONPath("path", "/user/7").
+		JSONPath("query", "search").
+		JSONPath("header", "yes").
+		JSONPath("content_type", "application/json").
+		JSONPath("id", "7").
+		JSONPath("ctx", "acme").
+		JSONPath("body", map[string]string{"email": "gopher@example.com"}).
+		JSONPath("items[1].id", 2)
+}
+
+func TestRequestBuilderUse(t *testing.T) {
+	setHeader := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Header.Set("X-Test", r.Header.Get("X-Test")+"outer")
+			next.ServeHTTP(w, r)
+		})
+	}
+	appendHeader := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Header.Set("X-Test", r.Header.Get("X-Test")+",inner")
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	New(t, api.Handler(echoHandler)).
+		Use(setHeader, appendHeader).
+		Get("/").
+		Do().
+		JSONPath("header", "outer,inner")
+}
+
+func TestResponseAppError(t *testing.T) {
+	res := New(t, api.Handler(echoHandler)).Get("/").Query("fail", "1").Do()
+	res.AppError(errors.KeyRequired("email")).
+		ErrorField("email")
+
+	if errs := failures(func(t testing.TB) {
+		res.t = t
+		res.AppError(errors.KeyRequired("name"))
+	}); len(errs) == 0 {
+		t.Error("AppError of the other field passed")
+	}
+}
+
+func TestResponseAssertionFailures(t *testing.T) {
+	res := New(t, api.Handler(echoHandler)).Get("/").Do()
+
+	tests := []struct {
+		name   string
+		assert func(res *Response)
+		want   string
+	}{
+		{"status", func(res *Response) { res.Status(http.StatusCreated) }, "status = 200, want 201"},
+		{"header", func(res *Response) { res.Header("X-Test", "no") }, `header X-Test = "", want "no"`},
+		{"path value", func(res *Response) { res.JSONPath("method", "POST") }, `method = "GET", want "POST"`},
+		{"missing path", func(res *Response) { res.JSONPath("items[5].id", 1) }, "JSON path items[5].id not found"},
+		{"decode", func(res *Response) { res.Decode(new(string)) }, "decode response body"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := failures(func(rt testing.TB) {
+				res.t = rt
+				tt.assert(res)
+			})
+			if len(errs) != 1 || !strings.Contains(errs[0], tt.want) {
+				t.Errorf("failures = %q, want %q", errs, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	tests := map[string][]string{
+		"data[0].email": {"data", "0", "email"},
+		"items.*.id":    {"items", "*", "id"},
+		"data[*].id":    {"data", "*", "id"},
+		"meta.total":    {"meta", "total"},
+		"":              nil,
+	}
+	for path, want := range tests {
+		if got := parsePath(path); !reflect.DeepEqual(got, want) {
+			t.Errorf("parsePath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestLookupAndMask(t *testing.T) {
+	doc := func() interface{} {
+		return map[string]interface{}{
+			"id": "u1",
+			"data": []interface{}{
+				map[string]interface{}{"id": "a", "tags": []interface{}{"x"}},
+				map[string]interface{}{"id": "b"},
+			},
+		}
+	}
+
+	lookups := []struct {
+		path string
+		want interface{}
+		ok   bool
+	}{
+		{"id", "u1", true},
+		{"data[1].id", "b", true},
+		{"data[0].tags[0]", "x", true},
+		{"data[2].id", nil, false},
+		{"data[-1].id", nil, false},
+		{"data.id", nil, false},
+		{"id.x", nil, false},
+	}
+	for _, tt := range lookups {
+		got, ok := lookup(doc(), parsePath(tt.path))
+		if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("lookup(%q) = %v, %v, want %v, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	masked := doc()
+	mask(masked, parsePath("data[*].id"), Masked)
+	mask(masked, parsePath("data[0].missing"), Masked)
+	mask(masked, parsePath("id"), Masked)
+	want := map[string]interface{}{
+		"id": Masked,
+		"data": []interface{}{
+			map[string]interface{}{"id": Masked, "tags": []interface{}{"x"}},
+			map[string]interface{}{"id": Masked},
+		},
+	}
+	if !reflect.DeepEqual(masked, want) {
+		t.Errorf("mask = %v, want %v", masked, want)
+	}
+}
+
+func TestGolden(t *testing.T) {
+	defer func(dir string) { GoldenDir = dir }(GoldenDir)
+	GoldenDir = t.TempDir()
+
+	res := New(t, api.Handler(echoHandler)).Get("/").Query("q", "one").Do()
+	masks := []string{"items[*].created_at"}
+
+	// missing golden file
+	if errs := failures(func(rt testing.TB) {
+		res.t = rt
+		res.Golden("echo", masks...)
+	}); len(errs) != 1 || !strings.Contains(errs[0], UpdateGoldenEnv+"=1") {
+		t.Fatalf("failures = %q, want the update hint", errs)
+	}
+
+	// update writes the golden file
+	t.Setenv(UpdateGoldenEnv, "1")
+	res.t = t
+	res.Golden("echo", masks...)
+	data, err := os.ReadFile(filepath.Join(GoldenDir, "echo.golden.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var golden struct {
+		Query string `json:"query"`
+		Items []struct {
+			CreatedAt string `json:"created_at"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(data, &golden); err != nil {
+		t.Fatal(err)
+	}
+	if golden.Query != "one" || len(golden.Items) != 2 || golden.Items[0].CreatedAt != Masked || golden.Items[1].CreatedAt != Masked {
+		t.Errorf("golden file isn't masked:\n%s", data)
+	}
+
+	// compares against the golden file
+	t.Setenv(UpdateGoldenEnv, "")
+	res.Golden("echo", masks...)
+
+	other := New(t, api.Handler(echoHandler)).Get("/").Query("q", "two").Do()
+	if errs := failures(func(rt testing.TB) {
+		other.t = rt
+		other.Golden("echo", masks...)
+	}); len(errs) != 1 || !strings.Contains(errs[0], "doesn't match the golden") {
+		t.Errorf("failures = %q, want the golden mismatch", errs)
+	}
+}
+
+func TestQuietLogsOverlapping(t *testing.T) {
+	var out strings.Builder
+	defer func(w io.Writer) { log.SetOutput(w) }(log.StandardLogger().Out)
+	log.SetOutput(&out)
+
+	// the parallel runs restore in any order
+	restoreA := quietLogs()
+	restoreB := quietLogs()
+	restoreA()
+	log.Info("quiet")
+	restoreB()
+	log.Info("restored")
+
+	if got := out.String(); strings.Contains(got, "quiet") || !strings.Contains(got, "restored") {
+		t.Errorf("logs = %q, want only the restored log", got)
+	}
+}
@@ -0,0 +1,617 @@
+package apitest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	stdlog "log"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/manigandand/adk/middleware"
+	log "github.com/sirupsen/logrus"
+)
+
+// Fuzz settings, exported so that it can be changed by developers
+var (
+	// FuzzIterations is the number of the generated payloads per run
+	FuzzIterations = 300
+	// FuzzSeedEnv is the env var which replays the run of the seed. Ex: FUZZ_SEED=42 go test ./...
+	FuzzSeedEnv = "FUZZ_SEED"
+	// FuzzFixtureDir is the directory of the failing payloads, they are
+	// replayed first on the next runs as the regression fixtures.
+	FuzzFixtureDir = filepath.Join("testdata", "fuzz")
+	// FuzzMaxDepth is the max depth of the generated nested values
+	FuzzMaxDepth = 4
+	// FuzzQuiet discards the app error & the panic logs while fuzzing. The std
+	// & the logrus loggers are global, the logs of the tests running in
+	// parallel with Fuzz, Ex: by t.Parallel(), are discarded as well.
+	FuzzQuiet = true
+)
+
+// FuzzFailure is the payload which failed the handler
+type FuzzFailure struct {
+	// Reason is one of "5xx", "panic" or "invalid app error"
+	Reason  string
+	Status  int
+	Detail  string
+	Payload []byte
+	// Fixture is the file the minimized payload is saved to
+	Fixture string
+}
+
+const (
+	fuzzServerError     = "5xx"
+	fuzzPanic           = "panic"
+	fuzzInvalidAppError = "invalid app error"
+)
+
+// invalidUTF8 is replaced with the invalid UTF-8 bytes in the rendered
+// payload, encoding/json would replace them with U+FFFD.
+const invalidUTF8 = "__apitest_invalid_utf8__"
+
+// Fuzz generates the random & the edge case JSON payloads of the request type
+// T, Ex: boundary numbers, huge strings, invalid UTF-8, nulls, wrong types &
+// deep nesting, and serves them with the request. The handler is wrapped by
+// middleware.Recoverer. The 5xx responses, the panics and the error responses
+// which are not the valid app error JSON fail the test. The failing payloads
+// are minimized and saved in FuzzFixtureDir as the regression fixtures.
+//
+// EX:
+//
+//	apitest.Fuzz[createUserReq](
+//		apitest.New(t, api.Handler(CreateUserHandler)).
+//			Post("/user").
+//			ContextValue("orgID", uint(7)),
+//	)
+func Fuzz[T any](req *Request) []*FuzzFailure {
+	t := req.t
+	t.Helper()
+
+	if FuzzQuiet {
+		defer quietLogs()()
+	}
+
+	seed := time.Now().UnixNano()
+	if v := os.Getenv(FuzzSeedEnv); v != "" {
+		if s, err := strconv.ParseInt(v, 10, 64); err == nil {
+			seed = s
+		}
+	}
+
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	f := &fuzzer{
+		req: req,
+		rnd: rand.New(rand.NewSource(seed)),
+	}
+	dir := filepath.Join(FuzzFixtureDir, fixtureName(typ))
+
+	var failures []*FuzzFailure
+	// regression fixtures
+	fixtures, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	for _, file := range fixtures {
+		payload, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		if fail := f.run(payload, true); fail != nil {
+			fail.Fixture = file
+			failures = append(failures, fail)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i < FuzzIterations; i++ {
+		doc, payload := f.payload(typ, i)
+		fail := f.run(payload, true)
+		if fail == nil {
+			continue
+		}
+
+		key := fail.Reason + strconv.Itoa(fail.Status)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if doc != nil {
+			payload = f.minimize(doc, fail.Reason)
+			if minimized := f.run(payload, false); minimized != nil {
+				fail = minimized
+			}
+		}
+		fail.Fixture = saveFixture(t, dir, payload)
+		failures = append(failures, fail)
+	}
+
+	for _, fail := range failures {
+		t.Errorf("apitest: fuzz %s [%d] %s\npayload: %.512s\nfixture: %s, seed: %s=%d",
+			fail.Reason, fail.Status, fail.Detail, fail.Payload, fail.Fixture, FuzzSeedEnv, seed,
+		)
+	}
+	return failures
+}
+
+type fuzzer struct {
+	req *Request
+	rnd *rand.Rand
+}
+
+// run serves the payload and returns the failure, if any. The panics are
+// caught by middleware.Recoverer on the recover runs.
+func (f *fuzzer) run(payload []byte, recoverer bool) (fail *FuzzFailure) {
+	var panicked interface{}
+	spy := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if rvr := recover(); rvr != nil {
+				panicked = rvr
+				if recoverer {
+					panic(rvr)
+				}
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}()
+		f.req.handler.ServeHTTP(w, r)
+	})
+
+	var h http.Handler = spy
+	if recoverer {
+		h = middleware.Recoverer(spy)
+	}
+
+	r := *f.req
+	r.header = f.req.header.Clone()
+	r.header.Set("Content-Type", "application/json")
+	r.body = bytes.NewReader(payload)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r.Build())
+
+	newFail := func(reason, detail string) *FuzzFailure {
+		return &FuzzFailure{
+			Reason:  reason,
+			Status:  rec.Code,
+			Detail:  detail,
+			Payload: payload,
+		}
+	}
+	switch {
+	case panicked != nil:
+		return newFail(fuzzPanic, fmt.Sprintf("%v", panicked))
+	case rec.Code >= http.StatusInternalServerError:
+		return newFail(fuzzServerError, rec.Body.String())
+	case rec.Code >= http.StatusBadRequest:
+		if detail := checkAppError(rec); detail != "" {
+			return newFail(fuzzInvalidAppError, detail)
+		}
+	}
+	return nil
+}
+
+// checkAppError returns why the error response is not the valid app error JSON
+func checkAppError(rec *httptest.ResponseRecorder) string {
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		return "error response is not JSON: " + rec.Body.String()
+	}
+
+	if status, ok := body["status"].(float64); !ok || int(status) != rec.Code {
+		return fmt.Sprintf("status %v doesn't match the response status: %s", body["status"], rec.Body.String())
+	}
+	if msg, ok := body["error"].(string); !ok || msg == "" {
+		return "error message is missing: " + rec.Body.String()
+	}
+	return ""
+}
+
+// payload returns the generated payload, doc is nil for the raw payloads
+// which are not minimized.
+func (f *fuzzer) payload(typ reflect.Type, i int) (interface{}, []byte) {
+	// every 10th payload is the raw edge case
+	if i%10 == 0 {
+		raw := rawPayloads(f.value(typ, 0))
+		return nil, raw[(i/10)%len(raw)]
+	}
+
+	doc := f.value(typ, 0)
+	return doc, render(doc)
+}
+
+func rawPayloads(doc interface{}) [][]byte {
+	valid := render(doc)
+	return [][]byte{
+		{},
+		[]byte("null"),
+		[]byte("[]"),
+		[]byte("{}"),
+		[]byte(`"payload"`),
+		[]byte("0"),
+		valid[:len(valid)/2],
+		append(valid, valid...),
+		[]byte("\xff\xfe"),
+		bytes.Repeat([]byte("["), 20000),
+		[]byte(strings.Repeat(`{"a":`, 5000) + "1" + strings.Repeat("}", 5000)),
+	}
+}
+
+// render marshals the doc, the invalid UTF-8 placeholders are replaced with the raw bytes
+func render(doc interface{}) []byte {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return []byte("null")
+	}
+	return bytes.ReplaceAll(data, []byte(invalidUTF8), []byte("\xff\xfe\xfd"))
+}
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// value generates the JSON value of the type
+func (f *fuzzer) value(t reflect.Type, depth int) interface{} {
+	// wrong types & nulls
+	if f.rnd.Intn(12) == 0 {
+		return f.wrongValue(depth)
+	}
+
+	ptr := reflect.PointerTo(t)
+	if ptr.Implements(jsonUnmarshalerType) || ptr.Implements(textUnmarshalerType) {
+		if f.rnd.Intn(2) == 0 {
+			// the JSON of the zero value, Ex: "0001-01-01T00:00:00Z"
+			if data, err := json.Marshal(reflect.New(t).Interface()); err == nil {
+				var v interface{}
+				if json.Unmarshal(data, &v) == nil {
+					return v
+				}
+			}
+		}
+		return f.str()
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		if f.rnd.Intn(4) == 0 {
+			return nil
+		}
+		return f.value(t.Elem(), depth)
+
+	case reflect.String:
+		return f.str()
+
+	case reflect.Bool:
+		return f.rnd.Intn(2) == 0
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return f.pick(intEdges)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return f.pick(uintEdges)
+
+	case reflect.Float32, reflect.Float64:
+		return f.pick(floatEdges)
+
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return f.str()
+		}
+		n := f.length(depth)
+		if t.Kind() == reflect.Array && f.rnd.Intn(2) == 0 {
+			n = t.Len()
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			items[i] = f.value(t.Elem(), depth+1)
+		}
+		return items
+
+	case reflect.Map:
+		n := f.length(depth)
+		obj := make(map[string]interface{}, n)
+		for i := 0; i < n; i++ {
+			key := fmt.Sprint(f.pick(intEdges))
+			if t.Key().Kind() == reflect.String {
+				key = f.str()
+			}
+			obj[key] = f.value(t.Elem(), depth+1)
+		}
+		return obj
+
+	case reflect.Struct:
+		obj := make(map[string]interface{})
+		if depth > FuzzMaxDepth {
+			return obj
+		}
+		f.fields(obj, t, depth)
+		if f.rnd.Intn(10) == 0 {
+			obj["apitest_unknown_field"] = f.wrongValue(depth)
+		}
+		return obj
+
+	case reflect.Interface:
+		return f.wrongValue(depth)
+	}
+	return nil
+}
+
+// fields generates the values of the exported fields by the json names,
+// the fields are randomly omitted.
+func (f *fuzzer) fields(obj map[string]interface{}, t reflect.Type, depth int) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+
+		ft := field.Type
+		if field.Anonymous && name == "" {
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				f.fields(obj, ft, depth)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if f.rnd.Intn(5) == 0 {
+			continue
+		}
+		obj[name] = f.value(ft, depth+1)
+	}
+}
+
+func (f *fuzzer) length(depth int) int {
+	if depth > FuzzMaxDepth {
+		return 0
+	}
+	switch f.rnd.Intn(10) {
+	case 0:
+		return 0
+	case 1:
+		return 100
+	}
+	return 1 + f.rnd.Intn(3)
+}
+
+func (f *fuzzer) pick(values []interface{}) interface{} {
+	return values[f.rnd.Intn(len(values))]
+}
+
+var (
+	intEdges = []interface{}{
+		json.Number("0"), json.Number("1"), json.Number("-1"),
+		json.Number("127"), json.Number("-128"), json.Number("32767"), json.Number("2147483647"),
+		json.Number("-2147483648"), json.Number("9223372036854775807"), json.Number("-9223372036854775808"),
+		json.Number("9223372036854775808"), json.Number("1.5"), json.Number("1e3"), json.Number("-0"),
+	}
+	uintEdges = []interface{}{
+		json.Number("0"), json.Number("1"), json.Number("255"), json.Number("65535"),
+		json.Number("4294967295"), json.Number("18446744073709551615"), json.Number("18446744073709551616"),
+		json.Number("-1"),
+	}
+	floatEdges = []interface{}{
+		json.Number("0"), json.Number("-0.0"), json.Number("1.5"), json.Number("-1"),
+		json.Number("1.7976931348623157e308"), json.Number("5e-324"), json.Number("1e309"),
+		json.Number("3.4028235e38"), json.Number("1e39"),
+	}
+	stringEdges = []interface{}{
+		"", " ", "a", "ü漢字🙂", "\u0000", "\n\t\r", "<script>alert(1)</script>",
+		"' OR 1=1 --", "null", "%s%n%x", "../../etc/passwd", "‮", invalidUTF8,
+		strings.Repeat("a", 1<<16), strings.Repeat("🙂", 4096),
+	}
+)
+
+func (f *fuzzer) str() string {
+	if f.rnd.Intn(3) == 0 {
+		b := make([]byte, f.rnd.Intn(32))
+		for i := range b {
+			b[i] = byte(' ' + f.rnd.Intn(95))
+		}
+		return string(b)
+	}
+	return f.pick(stringEdges).(string)
+}
+
+// wrongValue returns the value of any JSON type, Ex: null or the deep nesting
+func (f *fuzzer) wrongValue(depth int) interface{} {
+	switch f.rnd.Intn(8) {
+	case 0:
+		return nil
+	case 1:
+		return f.str()
+	case 2:
+		return f.pick(intEdges)
+	case 3:
+		return f.rnd.Intn(2) == 0
+	case 4:
+		return []interface{}{}
+	case 5:
+		return map[string]interface{}{}
+	case 6:
+		if depth <= FuzzMaxDepth {
+			return []interface{}{f.wrongValue(depth + 1)}
+		}
+		return nil
+	}
+
+	// deep nesting
+	var v interface{} = json.Number("1")
+	for i := 0; i < 1000; i++ {
+		v = []interface{}{v}
+	}
+	return v
+}
+
+// minimize shrinks the doc while it fails with the same reason
+func (f *fuzzer) minimize(doc interface{}, reason string) []byte {
+	fails := func(d interface{}) bool {
+		fail := f.run(render(d), false)
+		return fail != nil && fail.Reason == reason
+	}
+
+	for budget := 300; budget > 0; {
+		progressed := false
+		for _, candidate := range shrink(doc) {
+			budget--
+			if fails(candidate) {
+				doc = candidate
+				progressed = true
+				break
+			}
+			if budget <= 0 {
+				break
+			}
+		}
+		if !progressed {
+			break
+		}
+	}
+	return render(doc)
+}
+
+// shrink returns the candidates one step smaller than v, the subtrees are
+// shared but never modified.
+func shrink(v interface{}) []interface{} {
+	var out []interface{}
+	switch x := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(x))
+		for key := range x {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		with := func(key string, value interface{}, drop bool) map[string]interface{} {
+			obj := make(map[string]interface{}, len(x))
+			for k, v := range x {
+				obj[k] = v
+			}
+			if drop {
+				delete(obj, key)
+			} else {
+				obj[key] = value
+			}
+			return obj
+		}
+		for _, key := range keys {
+			out = append(out, with(key, nil, true))
+		}
+		for _, key := range keys {
+			for _, c := range shrink(x[key]) {
+				out = append(out, with(key, c, false))
+			}
+		}
+
+	case []interface{}:
+		if len(x) > 1 {
+			out = append(out, x[:len(x)/2], x[len(x)/2:])
+		}
+		if len(x) > 0 && len(x) <= 16 {
+			for i := range x {
+				items := append(append([]interface{}{}, x[:i]...), x[i+1:]...)
+				out = append(out, items)
+			}
+		}
+		for i := 0; i < len(x) && i < 16; i++ {
+			for _, c := range shrink(x[i]) {
+				items := append([]interface{}{}, x...)
+				items[i] = c
+				out = append(out, items)
+			}
+		}
+
+	case string:
+		if x != "" && x != invalidUTF8 && len(x) > 1 {
+			out = append(out, x[:len(x)/2])
+		}
+		if x != "" {
+			out = append(out, "")
+		}
+
+	case json.Number:
+		if x != "0" {
+			out = append(out, json.Number("0"))
+		}
+	}
+	return out
+}
+
+var fixtureNameReplacer = regexp.MustCompile(`[^0-9A-Za-z_.-]+`)
+
+func fixtureName(t reflect.Type) string {
+	name := t.String()
+	if name == "" {
+		name = "anonymous"
+	}
+	return fixtureNameReplacer.ReplaceAllString(name, "_")
+}
+
+func saveFixture(t testing.TB, dir string, payload []byte) string {
+	t.Helper()
+
+	sum := sha256.Sum256(payload)
+	file := filepath.Join(dir, hex.EncodeToString(sum[:4])+".json")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Logf("apitest: create fuzz fixture dir: %v", err)
+		return ""
+	}
+	if err := os.WriteFile(file, payload, 0o644); err != nil {
+		t.Logf("apitest: save fuzz fixture: %v", err)
+		return ""
+	}
+	return file
+}
+
+// quiet counts the running quietLogs, the outputs are restored by the last
+// one, so the parallel Fuzz runs don't restore the discarded outputs
+var quiet struct {
+	sync.Mutex
+	count     int
+	stdOut    io.Writer
+	logrusOut io.Writer
+}
+
+// quietLogs discards the std & the logrus logs, returns the restore func
+func quietLogs() func() {
+	quiet.Lock()
+	defer quiet.Unlock()
+
+	if quiet.count == 0 {
+		quiet.stdOut, quiet.logrusOut = stdlog.Writer(), log.StandardLogger().Out
+		stdlog.SetOutput(io.Discard)
+		log.SetOutput(io.Discard)
+	}
+	quiet.count++
+
+	return func() {
+		quiet.Lock()
+		defer quiet.Unlock()
+
+		if quiet.count--; quiet.count == 0 {
+			stdlog.SetOutput(quiet.stdOut)
+			log.SetOutput(quiet.logrusOut)
+		}
+	}
+}
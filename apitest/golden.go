@@ -0,0 +1,66 @@
+package apitest
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+)
+
+// Golden file settings, exported so that it can be changed by developers
+var (
+	// GoldenDir is the directory of the golden files, relative to the package under test
+	GoldenDir = "testdata"
+	// UpdateGoldenEnv is the env var which rewrites the golden files with the
+	// actual responses. Ex: UPDATE_GOLDEN=1 go test ./...
+	UpdateGoldenEnv = "UPDATE_GOLDEN"
+	// Masked replaces the values of the masked fields
+	Masked = "<masked>"
+)
+
+// Golden compares the JSON body with the golden file testdata/<name>.golden.json.
+// The volatile fields, Ex: ids & timestamps, are masked in the both. The
+// wildcard "*" matches all the array elements or object values.
+//
+// EX:
+//
+//	res.Golden("create_user", "id", "data[*].created_at")
+func (res *Response) Golden(name string, masks ...string) *Response {
+	res.t.Helper()
+
+	doc := res.doc()
+	for _, path := range masks {
+		mask(doc, parsePath(path), Masked)
+	}
+
+	got, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		res.t.Fatalf("apitest: marshal golden %s: %v", name, err)
+	}
+	got = append(got, '\n')
+
+	file := filepath.Join(GoldenDir, name+".golden.json")
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			res.t.Fatalf("apitest: create golden dir: %v", err)
+		}
+		if err := os.WriteFile(file, got, 0o644); err != nil {
+			res.t.Fatalf("apitest: write golden %s: %v", file, err)
+		}
+		return res
+	}
+
+	want, err := os.ReadFile(file)
+	if err != nil {
+		res.t.Fatalf("apitest: read golden %s: %v, run with %s=1 to create it", file, err, UpdateGoldenEnv)
+	}
+	var wantDoc interface{}
+	if err := json.Unmarshal(want, &wantDoc); err != nil {
+		res.t.Fatalf("apitest: decode golden %s: %v", file, err)
+	}
+	gotDoc, _ := normalizeJSON(doc)
+	if !reflect.DeepEqual(gotDoc, wantDoc) {
+		res.t.Errorf("apitest: body doesn't match the golden %s\ngot:\n%s\nwant:\n%s", file, got, want)
+	}
+	return res
+}
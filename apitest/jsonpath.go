@@ -0,0 +1,80 @@
+package apitest
+
+import (
+	"strconv"
+	"strings"
+)
+
+// wildcard matches all the elements of the array or the values of the object
+const wildcard = "*"
+
+// parsePath splits the JSON path into the keys & the indexes.
+// Ex: "data[0].email" -> ["data", "0", "email"], "items.*.id" -> ["items", "*", "id"]
+func parsePath(path string) []string {
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+
+	var tokens []string
+	for _, token := range strings.Split(path, ".") {
+		if token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// lookup returns the value at the path, the wildcard is not supported
+func lookup(doc interface{}, tokens []string) (interface{}, bool) {
+	for _, token := range tokens {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			v, ok := node[token]
+			if !ok {
+				return nil, false
+			}
+			doc = v
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			doc = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+// mask replaces the existing values at the path with the value, the wildcard
+// matches all the elements.
+func mask(doc interface{}, tokens []string, value interface{}) {
+	if len(tokens) == 0 {
+		return
+	}
+	token, last := tokens[0], len(tokens) == 1
+
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		for key := range node {
+			if token != wildcard && key != token {
+				continue
+			}
+			if last {
+				node[key] = value
+				continue
+			}
+			mask(node[key], tokens[1:], value)
+		}
+	case []interface{}:
+		for i := range node {
+			if token != wildcard && token != strconv.Itoa(i) {
+				continue
+			}
+			if last {
+				node[i] = value
+				continue
+			}
+			mask(node[i], tokens[1:], value)
+		}
+	}
+}
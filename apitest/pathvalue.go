@@ -0,0 +1,12 @@
+//go:build go1.22
+
+package apitest
+
+import "net/http"
+
+// setPathValues sets the path params readable by r.PathValue
+func setPathValues(r *http.Request, params [][2]string) {
+	for _, p := range params {
+		r.SetPathValue(p[0], p[1])
+	}
+}
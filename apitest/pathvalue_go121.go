@@ -0,0 +1,8 @@
+//go:build !go1.22
+
+package apitest
+
+import "net/http"
+
+// setPathValues is a no-op, r.PathValue is added in go 1.22
+func setPathValues(r *http.Request, params [][2]string) {}
@@ -0,0 +1,195 @@
+package apitest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Request is the fluent builder of the in-process request. The request is
+// served by the handler, which can be the api.Handler or the router with the
+// full middleware stack.
+//
+// EX:
+//
+//	apitest.New(t, api.Handler(CreateUserHandler)).
+//		Post("/user").
+//		ContextValue("orgID", uint(7)).
+//		JSON(map[string]interface{}{"email": "gopher@example.com"}).
+//		Do().
+//		Status(http.StatusOK).
+//		JSONPath("message", "user created successfully")
+type Request struct {
+	t       testing.TB
+	handler http.Handler
+
+	method     string
+	path       string
+	query      url.Values
+	header     http.Header
+	body       io.Reader
+	pathParams [][2]string
+	ctxValues  [][2]interface{}
+}
+
+// New returns the request builder of the handler
+func New(t testing.TB, h http.Handler) *Request {
+	return &Request{
+		t:       t,
+		handler: h,
+		method:  http.MethodGet,
+		path:    "/",
+		query:   url.Values{},
+		header:  http.Header{},
+	}
+}
+
+// Use wraps the handler with the net/http middlewares, the first middleware
+// is the outermost. Ex: Use(middleware.Logger, middleware.Recoverer)
+func (req *Request) Use(mws ...func(http.Handler) http.Handler) *Request {
+	for i := len(mws) - 1; i >= 0; i-- {
+		req.handler = mws[i](req.handler)
+	}
+	return req
+}
+
+// Method sets the method & the path of the request
+func (req *Request) Method(method, path string) *Request {
+	req.method = method
+	req.path = path
+	return req
+}
+
+// Get sets the GET method & the path of the request
+func (req *Request) Get(path string) *Request {
+	return req.Method(http.MethodGet, path)
+}
+
+// Post sets the POST method & the path of the request
+func (req *Request) Post(path string) *Request {
+	return req.Method(http.MethodPost, path)
+}
+
+// Put sets the PUT method & the path of the request
+func (req *Request) Put(path string) *Request {
+	return req.Method(http.MethodPut, path)
+}
+
+// Patch sets the PATCH method & the path of the request
+func (req *Request) Patch(path string) *Request {
+	return req.Method(http.MethodPatch, path)
+}
+
+// Delete sets the DELETE method & the path of the request
+func (req *Request) Delete(path string) *Request {
+	return req.Method(http.MethodDelete, path)
+}
+
+// Query adds the query param
+func (req *Request) Query(key, value string) *Request {
+	req.query.Add(key, value)
+	return req
+}
+
+// Header sets the request header
+func (req *Request) Header(key, value string) *Request {
+	req.header.Set(key, value)
+	return req
+}
+
+// PathParam sets the path param, used to call the handler without the router.
+// The param is readable by api.PathParam & r.PathValue of go 1.22+.
+func (req *Request) PathParam(name, value string) *Request {
+	req.pathParams = append(req.pathParams, [2]string{name, value})
+	return req
+}
+
+// ContextValue sets the value in the request context. Ex: the values set by
+// the auth middlewares.
+func (req *Request) ContextValue(key, value interface{}) *Request {
+	req.ctxValues = append(req.ctxValues, [2]interface{}{key, value})
+	return req
+}
+
+// JSON sets the JSON body of the request, the string & []byte are sent as it
+// is, the other values are marshalled.
+func (req *Request) JSON(v interface{}) *Request {
+	req.t.Helper()
+
+	switch body := v.(type) {
+	case string:
+		req.body = strings.NewReader(body)
+	case []byte:
+		req.body = bytes.NewReader(body)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			req.t.Fatalf("apitest: marshal request body: %v", err)
+		}
+		req.body = bytes.NewReader(data)
+	}
+
+	if req.header.Get("Content-Type") == "" {
+		req.header.Set("Content-Type", "application/json")
+	}
+	return req
+}
+
+// Body sets the raw body of the request
+func (req *Request) Body(body io.Reader) *Request {
+	req.body = body
+	return req
+}
+
+// Build returns the http request
+func (req *Request) Build() *http.Request {
+	target := req.path
+	if len(req.query) > 0 {
+		sep := "?"
+		if strings.Contains(target, "?") {
+			sep = "&"
+		}
+		target += sep + req.query.Encode()
+	}
+
+	r := httptest.NewRequest(req.method, target, req.body)
+	for key, values := range req.header {
+		r.Header[key] = values
+	}
+
+	ctx := r.Context()
+	for _, kv := range req.ctxValues {
+		ctx = context.WithValue(ctx, kv[0], kv[1])
+	}
+	if len(req.pathParams) > 0 {
+		rctx := chi.NewRouteContext()
+		for _, p := range req.pathParams {
+			rctx.URLParams.Add(p[0], p[1])
+		}
+		ctx = context.WithValue(ctx, chi.RouteCtxKey, rctx)
+	}
+
+	r = r.WithContext(ctx)
+	setPathValues(r, req.pathParams)
+	return r
+}
+
+// Do serves the request and returns the response to assert
+func (req *Request) Do() *Response {
+	req.t.Helper()
+
+	rec := httptest.NewRecorder()
+	req.handler.ServeHTTP(rec, req.Build())
+	return &Response{
+		t:        req.t,
+		Recorder: rec,
+	}
+}
@@ -0,0 +1,168 @@
+package apitest
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/manigandand/adk/errors"
+)
+
+// Response is the recorded response, the assertions report the failures with
+// t.Errorf and return the response, so they can be chained.
+type Response struct {
+	t        testing.TB
+	Recorder *httptest.ResponseRecorder
+}
+
+// Status asserts the status code
+func (res *Response) Status(want int) *Response {
+	res.t.Helper()
+
+	if got := res.Recorder.Code; got != want {
+		res.t.Errorf("apitest: status = %d, want %d\nbody: %s", got, want, res.Recorder.Body.String())
+	}
+	return res
+}
+
+// Header asserts the response header
+func (res *Response) Header(key, want string) *Response {
+	res.t.Helper()
+
+	if got := res.Recorder.Header().Get(key); got != want {
+		res.t.Errorf("apitest: header %s = %q, want %q", key, got, want)
+	}
+	return res
+}
+
+// JSON asserts the whole JSON body, the values are compared as JSON. Ex: the
+// struct & the map with the same JSON are equal.
+func (res *Response) JSON(want interface{}) *Response {
+	res.t.Helper()
+
+	res.assertJSON("body", res.doc(), want)
+	return res
+}
+
+// JSONPath asserts the JSON value at the path. Ex: "data[0].email", "meta.total"
+func (res *Response) JSONPath(path string, want interface{}) *Response {
+	res.t.Helper()
+
+	got, ok := lookup(res.doc(), parsePath(path))
+	if !ok {
+		res.t.Errorf("apitest: JSON path %s not found\nbody: %s", path, res.Recorder.Body.String())
+		return res
+	}
+	res.assertJSON(path, got, want)
+	return res
+}
+
+// Decode decodes the JSON body into v, for the custom assertions
+func (res *Response) Decode(v interface{}) *Response {
+	res.t.Helper()
+
+	if err := json.Unmarshal(res.Recorder.Body.Bytes(), v); err != nil {
+		res.t.Fatalf("apitest: decode response body: %v\nbody: %s", err, res.Recorder.Body.String())
+	}
+	return res
+}
+
+// AppError asserts the response is the app error JSON as sent by
+// respond.Fail, the status, message, field, error details & conflict data
+// must be equal.
+//
+// EX:
+//
+//	res.AppError(errors.KeyRequired("email"))
+func (res *Response) AppError(want *errors.AppError) *Response {
+	res.t.Helper()
+
+	res.Status(want.GetStatus())
+	res.assertJSON("app error", res.doc(), want)
+	return res
+}
+
+// ErrorMessage asserts the message of the app error
+func (res *Response) ErrorMessage(want string) *Response {
+	res.t.Helper()
+
+	if got := res.appError().Error(); got != want {
+		res.t.Errorf("apitest: app error message = %q, want %q", got, want)
+	}
+	return res
+}
+
+// ErrorField asserts the field of the app error. Ex: items[0].qty
+func (res *Response) ErrorField(want string) *Response {
+	res.t.Helper()
+
+	if got := res.appError().GetField(); got != want {
+		res.t.Errorf("apitest: app error field = %q, want %q", got, want)
+	}
+	return res
+}
+
+// ErrorCode asserts the internal error code of the app error. Ex: D0003
+func (res *Response) ErrorCode(want string) *Response {
+	res.t.Helper()
+
+	got, _ := lookup(res.doc(), parsePath("error_details.code"))
+	if got != want {
+		res.t.Errorf("apitest: app error code = %v, want %q", got, want)
+	}
+	return res
+}
+
+// ConflictData asserts the conflict data of the app error
+func (res *Response) ConflictData(want interface{}) *Response {
+	res.t.Helper()
+
+	got, _ := lookup(res.doc(), parsePath("conflict_data"))
+	res.assertJSON("conflict_data", got, want)
+	return res
+}
+
+func (res *Response) appError() *errors.AppError {
+	res.t.Helper()
+
+	appErr := new(errors.AppError)
+	res.Decode(appErr)
+	return appErr
+}
+
+// doc returns the decoded JSON body
+func (res *Response) doc() interface{} {
+	res.t.Helper()
+
+	var doc interface{}
+	res.Decode(&doc)
+	return doc
+}
+
+func (res *Response) assertJSON(name string, got, want interface{}) {
+	res.t.Helper()
+
+	wantDoc, err := normalizeJSON(want)
+	if err != nil {
+		res.t.Fatalf("apitest: marshal the expected %s: %v", name, err)
+	}
+	if !reflect.DeepEqual(got, wantDoc) {
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(wantDoc)
+		res.t.Errorf("apitest: %s = %s, want %s", name, gotJSON, wantJSON)
+	}
+}
+
+// normalizeJSON round trips the value through JSON, so it's comparable with
+// the decoded body.
+func normalizeJSON(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc interface{}
+	err = json.Unmarshal(data, &doc)
+	return doc, err
+}
@@ -0,0 +1,307 @@
+package apitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/manigandand/adk/errors"
+	"github.com/manigandand/adk/respond"
+)
+
+// BodyMatcher matches the request body of the expectation
+type BodyMatcher func(body []byte) bool
+
+// JSONEq matches the JSON body equal to v, the values are compared as JSON
+func JSONEq(v interface{}) BodyMatcher {
+	want, err := normalizeJSON(v)
+	return func(body []byte) bool {
+		var got interface{}
+		if err != nil || json.Unmarshal(body, &got) != nil {
+			return false
+		}
+		return reflect.DeepEqual(got, want)
+	}
+}
+
+// Contains matches the body which contains s
+func Contains(s string) BodyMatcher {
+	return func(body []byte) bool {
+		return bytes.Contains(body, []byte(s))
+	}
+}
+
+// RecordedRequest is the request received by the stub
+type RecordedRequest struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// Stub is the stub server of the upstream services. The expectations are
+// matched in the declared order and the call counts are verified when the
+// test finishes.
+//
+// EX:
+//
+//	users := apitest.NewStub(t)
+//	users.Expect(http.MethodGet, "/users/7").Once().Delay(50 * time.Millisecond).
+//		RespondError(errors.TooManyRequests("slow down"))
+//	users.Expect(http.MethodGet, "/users/7").Once().
+//		RespondJSON(http.StatusOK, map[string]interface{}{"id": 7})
+//
+//	client := NewUserClient(users.URL)
+type Stub struct {
+	*httptest.Server
+	t testing.TB
+
+	mu           sync.Mutex
+	expectations []*Expectation
+	requests     []*RecordedRequest
+	unmatched    []string
+}
+
+// NewStub starts the stub server, it's closed & verified by t.Cleanup
+func NewStub(t testing.TB) *Stub {
+	s := &Stub{t: t}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(func() {
+		s.Close()
+		s.Verify()
+	})
+	return s
+}
+
+// Expect declares the expectation of the request with the method & the path.
+// The path can be the glob pattern of path.Match, Ex: /users/*
+// The expectation is expected to be called once, see Times.
+func (s *Stub) Expect(method, pattern string) *Expectation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := &Expectation{
+		mu:      &s.mu,
+		method:  method,
+		pattern: pattern,
+		header:  http.Header{},
+		times:   1,
+		status:  http.StatusOK,
+	}
+	s.expectations = append(s.expectations, e)
+	return e
+}
+
+// Requests returns the requests received by the stub
+func (s *Stub) Requests() []*RecordedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*RecordedRequest(nil), s.requests...)
+}
+
+// Verify reports the expectations which are not called the expected times
+// and the unexpected requests. It's called by t.Cleanup.
+func (s *Stub) Verify() {
+	s.t.Helper()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range s.expectations {
+		if e.times >= 0 && e.calls != e.times {
+			s.t.Errorf("apitest: stub %s %s called %d times, want %d", e.method, e.pattern, e.calls, e.times)
+		}
+	}
+	for _, req := range s.unmatched {
+		s.t.Errorf("apitest: stub received the unexpected request %s", req)
+	}
+}
+
+func (s *Stub) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	s.mu.Lock()
+	s.requests = append(s.requests, &RecordedRequest{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Header: r.Header.Clone(),
+		Body:   body,
+	})
+	e := s.match(r, body)
+	if e == nil {
+		s.unmatched = append(s.unmatched, r.Method+" "+r.URL.RequestURI())
+	} else {
+		e.calls++
+	}
+	s.mu.Unlock()
+
+	if e == nil {
+		respond.Fail(w, errors.NewAppError(http.StatusNotImplemented, "apitest: no stub for "+r.Method+" "+r.URL.Path).WithNoLog())
+		return
+	}
+	e.respond(w, r)
+}
+
+// match returns the first matching expectation which is not exhausted, or the
+// last matching one, so the extra calls are reported by Verify.
+func (s *Stub) match(r *http.Request, body []byte) *Expectation {
+	var last *Expectation
+	for _, e := range s.expectations {
+		if !e.matches(r, body) {
+			continue
+		}
+		if e.times < 0 || e.calls < e.times {
+			return e
+		}
+		last = e
+	}
+	return last
+}
+
+// Expectation is the expected request and its canned response
+type Expectation struct {
+	mu      *sync.Mutex // guards calls
+	method  string
+	pattern string
+	header  http.Header
+	body    BodyMatcher
+	times   int // -1 is any times
+
+	status   int
+	resHead  http.Header
+	resBody  []byte
+	delay    time.Duration
+	dropConn bool
+
+	calls int
+}
+
+// Header expects the request header
+func (e *Expectation) Header(key, value string) *Expectation {
+	e.header.Set(key, value)
+	return e
+}
+
+// Body expects the request body matched by the matcher. Ex: JSONEq, Contains
+func (e *Expectation) Body(matcher BodyMatcher) *Expectation {
+	e.body = matcher
+	return e
+}
+
+// Times expects the request n times
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+// Once expects the request once
+func (e *Expectation) Once() *Expectation {
+	return e.Times(1)
+}
+
+// AnyTimes allows the request any number of times, including zero
+func (e *Expectation) AnyTimes() *Expectation {
+	return e.Times(-1)
+}
+
+// Respond responds the raw body with the status
+func (e *Expectation) Respond(status int, body string) *Expectation {
+	e.status = status
+	e.resBody = []byte(body)
+	return e
+}
+
+// RespondJSON responds the JSON of v with the status
+func (e *Expectation) RespondJSON(status int, v interface{}) *Expectation {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic("apitest: marshal stub response: " + err.Error())
+	}
+
+	e.status = status
+	e.resBody = data
+	return e.RespondHeader("Content-Type", "application/json")
+}
+
+// RespondError responds the app error JSON as sent by respond.Fail
+func (e *Expectation) RespondError(err *errors.AppError) *Expectation {
+	return e.RespondJSON(err.GetStatus(), err)
+}
+
+// RespondHeader sets the response header
+func (e *Expectation) RespondHeader(key, value string) *Expectation {
+	if e.resHead == nil {
+		e.resHead = http.Header{}
+	}
+	e.resHead.Set(key, value)
+	return e
+}
+
+// Delay delays the response, Ex: to test the client timeouts
+func (e *Expectation) Delay(d time.Duration) *Expectation {
+	e.delay = d
+	return e
+}
+
+// DropConnection closes the connection without the response, Ex: to test the retries
+func (e *Expectation) DropConnection() *Expectation {
+	e.dropConn = true
+	return e
+}
+
+// Calls returns the number of the matched calls
+func (e *Expectation) Calls() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.calls
+}
+
+func (e *Expectation) matches(r *http.Request, body []byte) bool {
+	if e.method != r.Method {
+		return false
+	}
+	if ok, _ := path.Match(e.pattern, r.URL.Path); !ok && e.pattern != r.URL.Path {
+		return false
+	}
+	for key := range e.header {
+		if r.Header.Get(key) != e.header.Get(key) {
+			return false
+		}
+	}
+	return e.body == nil || e.body(body)
+}
+
+func (e *Expectation) respond(w http.ResponseWriter, r *http.Request) {
+	if e.delay > 0 {
+		select {
+		case <-time.After(e.delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if e.dropConn {
+		if hj, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hj.Hijack(); err == nil {
+				conn.Close()
+				return
+			}
+		}
+		panic(http.ErrAbortHandler)
+	}
+
+	for key, values := range e.resHead {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(e.status)
+	_, _ = w.Write(e.resBody)
+}
@@ -0,0 +1,161 @@
+// Package background runs the fire-and-forget work spawned by the handlers.
+// The tasks run with the context detached from the request cancellation, but
+// keeping its values (request id, logger fields), the panics are recovered
+// and logged as the middleware.Recoverer and the in-flight tasks are tracked,
+// so the graceful shutdown can wait for them.
+package background
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/manigandand/adk/errors"
+	"github.com/manigandand/adk/middleware"
+	log "github.com/sirupsen/logrus"
+)
+
+// Task is the background work, the returned error is logged
+type Task func(ctx context.Context) *errors.AppError
+
+// DefaultGroup is the group of the package level Go, waited by the server
+// shutdown. Exported so that it can be changed by developers
+var DefaultGroup = NewGroup()
+
+// Go runs the task in the DefaultGroup.
+//
+// EX:
+//
+//	background.Go(r.Context(), "welcome-mail", func(ctx context.Context) *errors.AppError {
+//		return mailer.SendWelcome(ctx, user.Email)
+//	})
+func Go(ctx context.Context, name string, task Task) bool {
+	return DefaultGroup.Go(ctx, name, task)
+}
+
+// Group tracks the in-flight tasks
+type Group struct {
+	// Timeout limits the run time of the tasks, zero is no limit
+	Timeout time.Duration
+
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+	inFlight int
+	closed   bool
+
+	// cancel cancels the tasks which outlive the shutdown
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewGroup returns the task group
+func NewGroup() *Group {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Group{ctx: ctx, cancel: cancel}
+}
+
+// Go runs the task in the new goroutine with the context detached from the
+// ctx cancellation. The tasks are rejected, and logged, once the group is
+// shutting down, returns false then.
+func (g *Group) Go(ctx context.Context, name string, task Task) bool {
+	reqID := middleware.RequestID(ctx)
+
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		log.WithField("request_id", reqID).Warnf("background: task %s is rejected, shutting down", name)
+		return false
+	}
+	g.inFlight++
+	g.wg.Add(1)
+	g.mu.Unlock()
+
+	taskCtx, cancel := g.detach(ctx)
+	go func() {
+		defer g.done()
+		defer cancel()
+		run(taskCtx, name, reqID, task)
+	}()
+	return true
+}
+
+// detach returns the context with the values of ctx, which is cancelled only
+// by the group shutdown & the Timeout.
+func (g *Group) detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	detached, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(g.ctx, cancel)
+
+	if g.Timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		detached, cancelTimeout = context.WithTimeout(detached, g.Timeout)
+		return detached, func() {
+			stop()
+			cancelTimeout()
+			cancel()
+		}
+	}
+	return detached, func() {
+		stop()
+		cancel()
+	}
+}
+
+func (g *Group) done() {
+	g.mu.Lock()
+	g.inFlight--
+	g.mu.Unlock()
+	g.wg.Done()
+}
+
+// InFlight returns the number of the running tasks
+func (g *Group) InFlight() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.inFlight
+}
+
+// Shutdown rejects the new tasks and waits for the in-flight tasks until the
+// ctx is done, then the contexts of the remaining tasks are cancelled and
+// ctx.Err() is returned.
+func (g *Group) Shutdown(ctx context.Context) error {
+	g.mu.Lock()
+	g.closed = true
+	g.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		log.Warnf("background: %d tasks are cancelled by the shutdown", g.InFlight())
+		g.cancel()
+		return ctx.Err()
+	}
+}
+
+// Shutdown shuts down the DefaultGroup
+func Shutdown(ctx context.Context) error {
+	return DefaultGroup.Shutdown(ctx)
+}
+
+// run runs the task, the panics are recovered & logged as the middleware.Recoverer
+func run(ctx context.Context, name, reqID string, task Task) {
+	start := time.Now()
+	defer func() {
+		if rvr := recover(); rvr != nil {
+			middleware.LogPanic(rvr, "background task "+name+" request_id "+reqID)
+		}
+	}()
+
+	if err := task(ctx); err != nil {
+		err.Log()
+		log.WithField("request_id", reqID).
+			Errorf("background: task %s failed after %v: [%d] %s", name, time.Since(start), err.GetStatus(), err.Error())
+	}
+}
@@ -0,0 +1,128 @@
+// Command adk-replay replays the recorded traffic against the locally started
+// server and reports the status & the body diffs per endpoint. The command
+// can't load the handlers, replay them in-process with replay.New(router)
+// in the tests.
+//
+// EX:
+//
+//	go run ./cmd/adk-replay -target http://localhost:3000 \
+//		-ignore id,data[*].token -H "Authorization: Bearer test" -q api_key=test \
+//		-b password=test traffic.har.ndjson
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/manigandand/adk/replay"
+)
+
+type listFlags []string
+
+func (l *listFlags) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *listFlags) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+type headerFlags []string
+
+func (h *headerFlags) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headerFlags) Set(value string) error {
+	if !strings.Contains(value, ":") {
+		return fmt.Errorf("invalid header %q, want \"Key: value\"", value)
+	}
+	*h = append(*h, value)
+	return nil
+}
+
+func main() {
+	var (
+		headers headerFlags
+		query   listFlags
+		body    listFlags
+		target  = flag.String("target", "http://localhost:3000", "base url of the server")
+		ignore  = flag.String("ignore", "", "comma separated body fields ignored in addition to the defaults, Ex: id,data[*].token")
+		compare = flag.String("compare-headers", "Content-Type", "comma separated response headers compared")
+		timeout = flag.Duration("timeout", 10*time.Second, "timeout per request")
+		asJSON  = flag.Bool("json", false, "write the report as JSON")
+	)
+	flag.Var(&headers, "H", "header set on the replayed requests, Ex: \"Authorization: Bearer test\", repeatable")
+	flag.Var(&query, "q", "query param set on the replayed requests, Ex: api_key=test, repeatable")
+	flag.Var(&body, "b", "JSON body field set on the replayed requests at any depth, Ex: password=test, repeatable")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: adk-replay [flags] <file.har|file.ndjson>...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	entries, err := replay.Load(flag.Args()...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "adk-replay:", err)
+		os.Exit(2)
+	}
+
+	rp := replay.NewRemote(*target)
+	rp.Client = &http.Client{Timeout: *timeout}
+	rp.Ignore = append(rp.Ignore, splitList(*ignore)...)
+	rp.CompareHeaders = splitList(*compare)
+	for _, h := range headers {
+		key, value, _ := strings.Cut(h, ":")
+		rp.Header.Add(strings.TrimSpace(key), strings.TrimSpace(value))
+	}
+	for _, q := range query {
+		key, value, _ := strings.Cut(q, "=")
+		rp.Query.Add(key, value)
+	}
+	for _, b := range body {
+		key, value, _ := strings.Cut(b, "=")
+		rp.Body[key] = value
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	report := rp.Run(ctx, entries)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		err = report.Write(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "adk-replay:", err)
+		os.Exit(2)
+	}
+	if report.Failed() {
+		os.Exit(1)
+	}
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
@@ -0,0 +1,535 @@
+// Package config fills the typed config struct from the defaults, a local
+// YAML/JSON file, the env vars and the flags, in the increasing precedence.
+// The fields are checked by the required & the validate tags and by the
+// Validate() hooks, as the api payloads. The secrets can be read from the
+// files pointed by the <ENV>_FILE env vars, Ex: the docker/kubernetes secrets.
+//
+// EX:
+//
+//	type Config struct {
+//		config.Service
+//		MongoURI     string        `config:"mongo_uri" required:"true" secret:"true"`
+//		Timeout      time.Duration `config:"timeout" default:"5s" validate:"min=1s"`
+//		FeatureFlags []string      `config:"feature_flags"`
+//	}
+//
+//	var cfg Config
+//	if err := config.Load(&cfg); err != nil {
+//		log.Fatal(err)
+//	}
+//	cfg.Init() // api.InitService & the log level
+//
+// The field `MongoURI` above is read from:
+//
+//	file    mongo_uri: ...
+//	env     API_MONGO_URI or the file at API_MONGO_URI_FILE, with the Prefix "API"
+//	flag    -mongo-uri
+package config
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/manigandand/adk/api"
+	"github.com/manigandand/adk/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Config tags
+const (
+	// TagName is the key of the field, defaults to the snake case of the field name
+	TagName = "config"
+	// TagDefault is the default value
+	TagDefault = "default"
+	// TagRequired fails the load if the field is not set by any source
+	TagRequired = "required"
+	// TagValidate are the comma separated rules, see validate
+	TagValidate = "validate"
+	// TagSecret redacts the value in the Redact dump
+	TagSecret = "secret"
+)
+
+// Loader loads the config from the sources
+type Loader struct {
+	// Prefix is the prefix of the env vars, Ex: API -> API_MONGO_URI
+	Prefix string
+	// File is the YAML or JSON config file, by the extension. Defaults to the
+	// -config flag or the <Prefix>_CONFIG_FILE env var, the file is optional
+	// unless it's set.
+	File string
+	// FlagSet defaults to the new flag set of os.Args[0], which ignores the
+	// flags it doesn't define, Ex: the flags of the app or the -test.* flags.
+	// The given FlagSet parses the args strictly.
+	FlagSet *flag.FlagSet
+	// Args are the command line args, defaults to os.Args[1:]
+	Args []string
+	// LookupEnv defaults to os.LookupEnv
+	LookupEnv func(key string) (string, bool)
+}
+
+// DefaultLoader is the loader of Load, exported so that it can be changed by developers
+var DefaultLoader = &Loader{}
+
+// Load loads v, the pointer to the config struct, by the DefaultLoader
+func Load(v interface{}) error {
+	return DefaultLoader.Load(v)
+}
+
+// field is the leaf field of the config struct
+type field struct {
+	path  []string // config keys of the parents & the field
+	value reflect.Value
+	tag   reflect.StructTag
+	set   bool   // set by any source other than the default
+	from  string // the source, used in the errors. Ex: env API_PORT
+}
+
+func (f *field) key() string {
+	return strings.Join(f.path, ".")
+}
+
+// Load loads v from the defaults, the file, the env vars and the flags, then
+// validates it.
+func (l *Loader) Load(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config: load expects the pointer to the struct, got %T", v)
+	}
+
+	fields := collect(rv.Elem(), nil)
+	for _, f := range fields {
+		if def, ok := f.tag.Lookup(TagDefault); ok {
+			if err := setValue(f.value, def); err != nil {
+				return fmt.Errorf("config: default of %s: %w", f.key(), err)
+			}
+		}
+	}
+
+	// the flags are parsed first, so the -config flag can point the file
+	file, flagValues, err := l.parseFlags(fields)
+	if err != nil {
+		return err
+	}
+
+	if err := l.loadFile(fields, file); err != nil {
+		return err
+	}
+	if err := l.loadEnv(fields); err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if value, ok := flagValues[f]; ok {
+			if err := setValue(f.value, value); err != nil {
+				return fmt.Errorf("config: flag -%s: %w", flagName(f.path), err)
+			}
+			f.set, f.from = true, "flag -"+flagName(f.path)
+		}
+	}
+
+	return l.validate(v, fields)
+}
+
+// collect returns the leaf fields of the struct, the embedded structs are
+// flattened and the nested structs are prefixed by their key.
+func collect(v reflect.Value, path []string) []*field {
+	var fields []*field
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(sf.Tag.Get(TagName), ",")
+		if name == "-" {
+			continue
+		}
+		fv := v.Field(i)
+
+		if isNested(sf.Type) {
+			if sf.Anonymous && name == "" {
+				fields = append(fields, collect(fv, path)...)
+				continue
+			}
+			if name == "" {
+				name = snakeCase(sf.Name)
+			}
+			fields = append(fields, collect(fv, appendPath(path, name))...)
+			continue
+		}
+
+		if name == "" {
+			name = snakeCase(sf.Name)
+		}
+		fields = append(fields, &field{path: appendPath(path, name), value: fv, tag: sf.Tag})
+	}
+	return fields
+}
+
+func appendPath(path []string, name string) []string {
+	return append(append([]string(nil), path...), name)
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// isNested reports whether the struct is the nested config, not the value
+// type, Ex: time.Time
+func isNested(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && !reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// snakeCase converts the field name, Ex: MongoURI -> mongo_uri, HTTPPort -> http_port
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prevLower := unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || (nextLower && unicode.IsUpper(runes[i-1])) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+func (l *Loader) envName(path []string) string {
+	name := strings.ToUpper(strings.Join(path, "_"))
+	if l.Prefix != "" {
+		name = strings.ToUpper(l.Prefix) + "_" + name
+	}
+	return name
+}
+
+func flagName(path []string) string {
+	return strings.ReplaceAll(strings.Join(path, "-"), "_", "-")
+}
+
+func (l *Loader) lookupEnv(key string) (string, bool) {
+	if l.LookupEnv != nil {
+		return l.LookupEnv(key)
+	}
+	return os.LookupEnv(key)
+}
+
+// flagValue records the value of the flag, applied after the file & the env
+type flagValue struct {
+	f      *field
+	values map[*field]string
+}
+
+func (fv *flagValue) String() string {
+	return ""
+}
+
+func (fv *flagValue) Set(s string) error {
+	fv.values[fv.f] = s
+	return nil
+}
+
+// IsBoolFlag allows -debug for the bool fields
+func (fv *flagValue) IsBoolFlag() bool {
+	return fv.f.value.Kind() == reflect.Bool
+}
+
+func (l *Loader) parseFlags(fields []*field) (string, map[*field]string, error) {
+	fs := l.FlagSet
+	if fs == nil {
+		fs = flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	}
+	args := l.Args
+	if args == nil {
+		args = os.Args[1:]
+	}
+	strict := l.FlagSet != nil
+
+	values := map[*field]string{}
+	for _, f := range fields {
+		usage := "env " + l.envName(f.path)
+		if def, ok := f.tag.Lookup(TagDefault); ok {
+			usage += ", default " + def
+		}
+		if _, ok := f.tag.Lookup(TagRequired); ok {
+			usage += ", required"
+		}
+		fs.Var(&flagValue{f: f, values: values}, flagName(f.path), usage)
+	}
+
+	file := l.File
+	if fs.Lookup("config") == nil {
+		fs.StringVar(&file, "config", l.File, "config file, YAML or JSON, env "+l.envName([]string{"config_file"}))
+	}
+	if !strict {
+		args = knownFlags(fs, args)
+	}
+	if err := fs.Parse(args); err != nil {
+		return "", nil, err
+	}
+
+	if file == "" {
+		file, _ = l.lookupEnv(l.envName([]string{"config_file"}))
+	}
+	return file, values, nil
+}
+
+// knownFlags returns the args of the flags defined in fs. The value of the
+// unknown flag is dropped with it unless it's in the -name=value form or the
+// next arg is a flag, the positional args are dropped as well.
+func knownFlags(fs *flag.FlagSet, args []string) []string {
+	var known []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			continue
+		}
+
+		name, _, hasValue := strings.Cut(strings.TrimPrefix(arg[1:], "-"), "=")
+		hasNext := !hasValue && i+1 < len(args)
+		f := fs.Lookup(name)
+		if f == nil {
+			if name == "h" || name == "help" {
+				known = append(known, arg)
+			} else if hasNext && !strings.HasPrefix(args[i+1], "-") {
+				i++
+			}
+			continue
+		}
+
+		known = append(known, arg)
+		if bf, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && bf.IsBoolFlag() {
+			continue
+		}
+		if hasNext {
+			known = append(known, args[i+1])
+			i++
+		}
+	}
+	return known
+}
+
+func (l *Loader) loadFile(fields []*field, file string) error {
+	if file == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	var doc map[string]interface{}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+		dec := json.NewDecoder(strings.NewReader(string(data)))
+		dec.UseNumber()
+		err = dec.Decode(&doc)
+	default:
+		err = yaml.Unmarshal(data, &doc)
+	}
+	if err != nil {
+		return fmt.Errorf("config: decode %s: %w", file, err)
+	}
+
+	for _, f := range fields {
+		value, ok := lookup(doc, f.path)
+		if !ok {
+			continue
+		}
+		if err := setFileValue(f.value, value); err != nil {
+			return fmt.Errorf("config: %s %s: %w", file, f.key(), err)
+		}
+		f.set, f.from = true, file+" "+f.key()
+	}
+	return nil
+}
+
+// lookup returns the file value at the path
+func lookup(doc map[string]interface{}, path []string) (interface{}, bool) {
+	var node interface{} = doc
+	for _, key := range path {
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if node, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+
+	switch node.(type) {
+	case nil, map[string]interface{}:
+		return nil, false
+	}
+	return node, true
+}
+
+// setFileValue sets the file value on the field, the lists are set item by
+// item so the items can have the commas.
+func setFileValue(v reflect.Value, node interface{}) error {
+	items, ok := node.([]interface{})
+	if !ok {
+		return setValue(v, fmt.Sprint(node))
+	}
+
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice {
+		return fmt.Errorf("unexpected list for %s", v.Type())
+	}
+	slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+	for i, item := range items {
+		if err := setFileValue(slice.Index(i), item); err != nil {
+			return fmt.Errorf("[%d]: %w", i, err)
+		}
+	}
+	v.Set(slice)
+	return nil
+}
+
+func (l *Loader) loadEnv(fields []*field) error {
+	for _, f := range fields {
+		name := l.envName(f.path)
+		value, ok := l.lookupEnv(name)
+		from := "env " + name
+
+		// secret file indirection, Ex: API_MONGO_URI_FILE=/run/secrets/mongo_uri
+		if file, fileOK := l.lookupEnv(name + "_FILE"); fileOK && !ok {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf("config: %s_FILE: %w", name, err)
+			}
+			value, ok, from = strings.TrimRight(string(data), "\r\n"), true, "env "+name+"_FILE"
+		}
+		if !ok {
+			continue
+		}
+
+		if err := setValue(f.value, value); err != nil {
+			return fmt.Errorf("config: %s: %w", from, err)
+		}
+		f.set, f.from = true, from
+	}
+	return nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setValue sets the string value on the field, the slices are comma separated
+func setValue(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setValue(v.Elem(), s)
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setValue(slice.Index(i), item); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// validate checks the required & the validate tags of all the fields, then
+// runs the Validate() hooks as api.Validate.
+func (l *Loader) validate(v interface{}, fields []*field) error {
+	var errs []string
+	for _, f := range fields {
+		name := l.envName(f.path)
+		if required, ok := f.tag.Lookup(TagRequired); ok && required != "false" && !f.set && f.value.IsZero() {
+			errs = append(errs, errors.KeyRequired(f.key()).Error()+" (env "+name+", flag -"+flagName(f.path)+")")
+			continue
+		}
+		if rules := f.tag.Get(TagValidate); rules != "" && (f.set || !f.value.IsZero()) {
+			if err := validateRules(f.value, rules); err != nil {
+				from := f.from
+				if from == "" {
+					from = "default"
+				}
+				errs = append(errs, fmt.Sprintf("%s %s (%s)", f.key(), err, from))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("config: invalid config:\n  %s", strings.Join(errs, "\n  "))
+	}
+
+	if err := api.Validate(context.Background(), v); err != nil {
+		if field := err.GetField(); field != "" {
+			return fmt.Errorf("config: invalid config: %s (%s)", err.Error(), field)
+		}
+		return fmt.Errorf("config: invalid config: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/manigandand/adk/errors"
+)
+
+type testDB struct {
+	URI  string `config:"uri" required:"true" secret:"true"`
+	Pool int    `config:"pool" default:"4" validate:"min=1,max=64"`
+}
+
+type testConfig struct {
+	Name    string        `config:"name" default:"default"`
+	Level   string        `config:"level" default:"info" validate:"oneof=debug info"`
+	Timeout time.Duration `config:"timeout" default:"1s"`
+	Debug   bool          `config:"debug"`
+	Tags    []string      `config:"tags"`
+	Ports   []int         `config:"ports"`
+	DB      testDB        `config:"db"`
+}
+
+func (c *testConfig) Validate() *errors.AppError {
+	if c.Name == "invalid" {
+		return errors.BadRequest("name is invalid").WithField("name")
+	}
+	return nil
+}
+
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}
+}
+
+func writeFile(t *testing.T, name, data string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := writeFile(t, "config.yaml", `
+name: file
+level: debug
+timeout: 2s
+db:
+  uri: mongodb://file
+  pool: 8
+`)
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want testConfig
+	}{
+		{
+			name: "file over defaults",
+			want: testConfig{Name: "file", Level: "debug", Timeout: 2 * time.Second, DB: testDB{URI: "mongodb://file", Pool: 8}},
+		},
+		{
+			name: "env over file",
+			env:  map[string]string{"API_NAME": "env", "API_DB_POOL": "16"},
+			want: testConfig{Name: "env", Level: "debug", Timeout: 2 * time.Second, DB: testDB{URI: "mongodb://file", Pool: 16}},
+		},
+		{
+			name: "flag over env",
+			env:  map[string]string{"API_NAME": "env", "API_DB_POOL": "16"},
+			args: []string{"-name", "flag", "-db-pool=32", "-debug"},
+			want: testConfig{Name: "flag", Level: "debug", Timeout: 2 * time.Second, Debug: true, DB: testDB{URI: "mongodb://file", Pool: 32}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &Loader{Prefix: "API", File: file, Args: tt.args, LookupEnv: env(tt.env)}
+			var got testConfig
+			if err := l.Load(&got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Load() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	l := &Loader{Args: []string{}, LookupEnv: env(map[string]string{"DB_URI": "mongodb://env"})}
+	var got testConfig
+	if err := l.Load(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := testConfig{Name: "default", Level: "info", Timeout: time.Second, DB: testDB{URI: "mongodb://env", Pool: 4}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Load() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLoadFileFromFlagAndEnv(t *testing.T) {
+	file := writeFile(t, "config.json", `{"db": {"uri": "mongodb://json"}}`)
+
+	for name, l := range map[string]*Loader{
+		"flag": {Prefix: "API", Args: []string{"-config", file}, LookupEnv: env(nil)},
+		"env":  {Prefix: "API", Args: []string{}, LookupEnv: env(map[string]string{"API_CONFIG_FILE": file})},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var got testConfig
+			if err := l.Load(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got.DB.URI != "mongodb://json" {
+				t.Errorf("db.uri = %q, want mongodb://json", got.DB.URI)
+			}
+		})
+	}
+}
+
+func TestLoadSecretFile(t *testing.T) {
+	secret := writeFile(t, "mongo_uri", "mongodb://secret\n")
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{"file", map[string]string{"API_DB_URI_FILE": secret}, "mongodb://secret"},
+		{"env wins", map[string]string{"API_DB_URI_FILE": secret, "API_DB_URI": "mongodb://env"}, "mongodb://env"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &Loader{Prefix: "API", Args: []string{}, LookupEnv: env(tt.env)}
+			var got testConfig
+			if err := l.Load(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got.DB.URI != tt.want {
+				t.Errorf("db.uri = %q, want %q", got.DB.URI, tt.want)
+			}
+		})
+	}
+
+	l := &Loader{Prefix: "API", Args: []string{}, LookupEnv: env(map[string]string{"API_DB_URI_FILE": secret + ".missing"})}
+	if err := l.Load(&testConfig{}); err == nil || !strings.Contains(err.Error(), "API_DB_URI_FILE") {
+		t.Errorf("Load() = %v, want the API_DB_URI_FILE error", err)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want []string
+	}{
+		{"required", nil, []string{"db.uri is required", "env API_DB_URI", "flag -db-uri"}},
+		{"min", map[string]string{"API_DB_URI": "u", "API_DB_POOL": "0"}, []string{"db.pool", "env API_DB_POOL"}},
+		{"oneof", map[string]string{"API_DB_URI": "u", "API_LEVEL": "trace"}, []string{"level", "env API_LEVEL"}},
+		{"parse", map[string]string{"API_DB_URI": "u", "API_TIMEOUT": "soon"}, []string{"env API_TIMEOUT"}},
+		{"validate hook", map[string]string{"API_DB_URI": "u", "API_NAME": "invalid"}, []string{"name is invalid (name)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &Loader{Prefix: "API", Args: []string{}, LookupEnv: env(tt.env)}
+			err := l.Load(&testConfig{})
+			if err == nil {
+				t.Fatal("Load() = nil, want the error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Load() = %q, want %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadUnknownFlags(t *testing.T) {
+	args := []string{"-test.v", "-test.run", "TestX", "-name", "flag", "-verbose", "-db-uri=u", "-port", "8080", "-debug", "arg", "--", "-level", "trace"}
+	l := &Loader{Args: args, LookupEnv: env(nil)}
+	var got testConfig
+	if err := l.Load(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "flag" || got.DB.URI != "u" || !got.Debug || got.Level != "info" {
+		t.Errorf("Load() = %+v, want the known flags only", got)
+	}
+
+	// the given FlagSet parses strictly
+	strict := &Loader{FlagSet: flag.NewFlagSet("api", flag.ContinueOnError), Args: []string{"-port", "8080"}, LookupEnv: env(nil)}
+	strict.FlagSet.SetOutput(new(strings.Builder))
+	if err := strict.Load(&testConfig{}); err == nil || !strings.Contains(err.Error(), "-port") {
+		t.Errorf("Load() = %v, want the undefined flag error", err)
+	}
+}
+
+func TestLoadFileLists(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		data string
+	}{
+		{"yaml", "config.yaml", "tags: [\"a,b\", c]\nports: [80, 443]\ndb: {uri: u}\n"},
+		{"json", "config.json", `{"tags": ["a,b", "c"], "ports": [80, 443], "db": {"uri": "u"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &Loader{File: writeFile(t, tt.file, tt.data), Args: []string{}, LookupEnv: env(nil)}
+			var got testConfig
+			if err := l.Load(&got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Tags, []string{"a,b", "c"}) || !reflect.DeepEqual(got.Ports, []int{80, 443}) {
+				t.Errorf("tags = %q, ports = %v, want [a,b c] [80 443]", got.Tags, got.Ports)
+			}
+		})
+	}
+
+	// the env & the flags are comma separated
+	l := &Loader{Args: []string{"-ports", "80, 443"}, LookupEnv: env(map[string]string{"TAGS": "a,b", "DB_URI": "u"})}
+	var got testConfig
+	if err := l.Load(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Tags, []string{"a", "b"}) || !reflect.DeepEqual(got.Ports, []int{80, 443}) {
+		t.Errorf("tags = %q, ports = %v, want [a b] [80 443]", got.Tags, got.Ports)
+	}
+
+	for data, want := range map[string]string{
+		"ports: [80, http]\n": "ports: [1]",
+		"name: [a]\n":         "name: unexpected list",
+	} {
+		l := &Loader{File: writeFile(t, "config.yaml", data), Args: []string{}, LookupEnv: env(nil)}
+		if err := l.Load(&testConfig{}); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Load(%q) = %v, want %q", data, err, want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Redacted replaces the values of the secret fields in the dump
+const Redacted = "[REDACTED]"
+
+// Redact returns the dump of the config struct keyed by the config keys, the
+// values of the fields tagged `secret:"true"` are redacted. Served on the
+// admin /config endpoint.
+func Redact(v interface{}) map[string]interface{} {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return map[string]interface{}{}
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return map[string]interface{}{}
+	}
+
+	dump := map[string]interface{}{}
+	for _, f := range collect(rv, nil) {
+		node := dump
+		for _, key := range f.path[:len(f.path)-1] {
+			child, ok := node[key].(map[string]interface{})
+			if !ok {
+				child = map[string]interface{}{}
+				node[key] = child
+			}
+			node = child
+		}
+		node[f.path[len(f.path)-1]] = dumpValue(f)
+	}
+	return dump
+}
+
+func dumpValue(f *field) interface{} {
+	if secret := f.tag.Get(TagSecret); secret != "" && secret != "false" {
+		if f.value.IsZero() {
+			return ""
+		}
+		return Redacted
+	}
+
+	v := f.value
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch x := v.Interface().(type) {
+	case time.Duration:
+		return x.String()
+	case fmt.Stringer:
+		return x.String()
+	}
+	return v.Interface()
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"time"
+
+	"github.com/manigandand/adk/api"
+	"github.com/manigandand/adk/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Service is the service config, embed it in the config struct and pass it
+// to server.FromConfig.
+type Service struct {
+	Name    string `config:"service_name" required:"true"`
+	Version string `config:"service_version" default:"dev"`
+	// Addr is the listen address, Ex: :3000, unix:/run/api.sock, systemd
+	Addr string `config:"addr" default:":3000"`
+	// AdminAddr is the address of the admin listener, empty disables it
+	AdminAddr       string        `config:"admin_addr"`
+	CertFile        string        `config:"tls_cert_file"`
+	KeyFile         string        `config:"tls_key_file"`
+	H2C             bool          `config:"h2c"`
+	ShutdownTimeout time.Duration `config:"shutdown_timeout" default:"30s" validate:"min=0s"`
+	// DrainDelay is waited after failing the readiness, before the shutdown
+	DrainDelay time.Duration `config:"drain_delay" default:"0s" validate:"min=0s"`
+	LogLevel   string        `config:"log_level" default:"info" validate:"oneof=trace debug info warn warning error fatal panic"`
+}
+
+// Init initializes the service, api.InitService & the log level
+func (s *Service) Init() {
+	api.InitService(s.Name, s.Version)
+	if level, err := log.ParseLevel(s.LogLevel); err == nil {
+		log.SetLevel(level)
+	}
+}
+
+// Validate implements the custom validator interface
+func (s *Service) Validate() *errors.AppError {
+	if (s.CertFile == "") != (s.KeyFile == "") {
+		return errors.BadRequest("tls_cert_file and tls_key_file must be set together").WithField("tls_cert_file")
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// validateRules checks the comma separated rules of the validate tag:
+//
+//	min=1, max=65535    the numbers & the durations, Ex: min=1s
+//	                    the length of the strings & the slices
+//	oneof=debug info    one of the space separated values
+//	url                 the absolute url with the host
+func validateRules(v reflect.Value, rules string) error {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		var err error
+		switch name {
+		case "min":
+			err = checkBound(v, arg, func(n, bound float64) bool { return n >= bound }, "must be at least")
+		case "max":
+			err = checkBound(v, arg, func(n, bound float64) bool { return n <= bound }, "must be at most")
+		case "oneof":
+			err = checkOneOf(v, arg)
+		case "url":
+			err = checkURL(v)
+		case "":
+		default:
+			err = fmt.Errorf("has the unknown validate rule %q", name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkBound compares the number, the duration or the length with the bound
+func checkBound(v reflect.Value, arg string, ok func(n, bound float64) bool, msg string) error {
+	var n, bound float64
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(arg)
+		if err != nil {
+			return fmt.Errorf("has the invalid bound %q: %w", arg, err)
+		}
+		n, bound = float64(v.Int()), float64(d)
+		if !ok(n, bound) {
+			return fmt.Errorf("%s %s", msg, arg)
+		}
+		return nil
+	}
+
+	bound, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return fmt.Errorf("has the invalid bound %q: %w", arg, err)
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = v.Float()
+	case reflect.String, reflect.Slice, reflect.Map:
+		n = float64(v.Len())
+		msg += " length"
+	default:
+		return fmt.Errorf("can't be bound checked, type %s", v.Type())
+	}
+
+	if !ok(n, bound) {
+		return fmt.Errorf("%s %s", msg, arg)
+	}
+	return nil
+}
+
+func checkOneOf(v reflect.Value, arg string) error {
+	value := fmt.Sprint(v.Interface())
+	for _, allowed := range strings.Fields(arg) {
+		if value == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("must be one of %s, got %q", strings.Join(strings.Fields(arg), ", "), value)
+}
+
+func checkURL(v reflect.Value) error {
+	if v.Kind() != reflect.String {
+		return fmt.Errorf("can't be checked as url, type %s", v.Type())
+	}
+
+	u, err := url.Parse(v.String())
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		// the credentials in the url are not logged
+		return fmt.Errorf("must be an absolute url")
+	}
+	return nil
+}
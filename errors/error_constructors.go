@@ -39,14 +39,27 @@ func Wrap(err error, message string) error {
 	return errors.Wrap(err, message)
 }
 
+// Is github.com/pkg/errors.Is
+// Is reports whether any error in err's chain matches target.
+func Is(err, target error) bool {
+	return errors.Is(err, target)
+}
+
+// As github.com/pkg/errors.As
+// As finds the first error in err's chain that matches target, and if so, sets
+// target to that error value and returns true.
+func As(err error, target interface{}) bool {
+	return errors.As(err, target)
+}
+
 // KeyRequired returns new error with custom error message
 func KeyRequired(key string) *AppError {
-	return BadRequest(key + " is required")
+	return BadRequest(key + " is required").WithField(key)
 }
 
 // InvalidKey returns new error with custom error message
 func InvalidKey(val, key string) *AppError {
-	return BadRequest(val + " is invalid " + key)
+	return BadRequest(val + " is invalid " + key).WithField(key)
 }
 
 // 4xx -------------------------------------------------------------------------
@@ -76,6 +89,11 @@ func NotFound(message string) *AppError { // 404
 	return NewAppError(http.StatusNotFound, message)
 }
 
+// NotAcceptable will return `http.StatusNotAcceptable` with custom message.
+func NotAcceptable(message string) *AppError { // 406
+	return NewAppError(http.StatusNotAcceptable, message)
+}
+
 // Conflict will return `http.StatusConflict` with custom message.
 func Conflict(message string) *AppError { // 409
 	return NewAppError(http.StatusConflict, message)
@@ -86,6 +104,12 @@ func Gone(message string) *AppError { // 410
 	return NewAppError(http.StatusGone, message)
 }
 
+// RequestEntityTooLarge will return `http.StatusRequestEntityTooLarge` with
+// custom message.
+func RequestEntityTooLarge(message string) *AppError { // 413
+	return NewAppError(http.StatusRequestEntityTooLarge, message)
+}
+
 // UnprocessableEntity will return `http.StatusUnprocessableEntity` with
 // custom message.
 func UnprocessableEntity(message string) *AppError { // 422
@@ -21,6 +21,7 @@ type AppError struct {
 	debug        error       // `json:"-"`
 	conflictData interface{} // `json:"conflict_data,omitempty"` // Add any relevant trace info for debug
 	errorDetails *Details    // `json:"error_details,omitempty"` // Custom internal error codes
+	field        string      // `json:"field,omitempty"` // path of the invalid field. Ex: items[3].sku
 	disableLog   bool        // `json:"-"` // disable's the log, log trace in Log()
 }
 
@@ -75,6 +76,9 @@ func (err *AppError) MarshalJSON() ([]byte, error) {
 	if err.errorDetails != nil {
 		m["error_details"] = err.errorDetails
 	}
+	if err.field != "" {
+		m["field"] = err.field
+	}
 
 	return json.Marshal(m)
 }
@@ -90,6 +94,7 @@ func (err *AppError) UnmarshalJSON(b []byte) error {
 		Message      string      `json:"error"`
 		ConflictData interface{} `json:"conflict_data"`
 		ErrorDetails *Details    `json:"error_details"`
+		Field        string      `json:"field"`
 	}
 	if err := json.Unmarshal(b, &appErr); err != nil {
 		return err
@@ -99,6 +104,7 @@ func (err *AppError) UnmarshalJSON(b []byte) error {
 	err.message = appErr.Message
 	err.conflictData = appErr.ConflictData
 	err.errorDetails = appErr.ErrorDetails
+	err.field = appErr.Field
 	return nil
 }
 
@@ -223,6 +229,56 @@ func (err *AppError) AddErrorDetail(d *Details) *AppError {
 	return err
 }
 
+// WithField sets the path of the field which caused the error. Ex: email, items[3].sku
+func (err *AppError) WithField(field string) *AppError {
+	if err != nil {
+		err.field = field
+	}
+
+	return err
+}
+
+// GetField returns the path of the field which caused the error if present
+func (err *AppError) GetField() string {
+	return err.field
+}
+
+// PrefixField returns the copy of the error with the field path prefixed with
+// the path of the parent field, err is not changed as the validators may
+// return the shared errors. Ex: sku prefixed with items[3] becomes items[3].sku
+func (err *AppError) PrefixField(prefix string) *AppError {
+	if err == nil || prefix == "" {
+		return err
+	}
+
+	cp := *err
+	switch {
+	case err.field == "":
+		cp.field = prefix
+	case strings.HasPrefix(err.field, "["):
+		cp.field = prefix + err.field
+	default:
+		cp.field = prefix + "." + err.field
+	}
+	return &cp
+}
+
+// Clone returns the copy of the error with its own error details, so the
+// copies can be changed independently. Ex: the same error responded to the
+// many requests. The debug errors are immutable & shared.
+func (err *AppError) Clone() *AppError {
+	if err == nil {
+		return nil
+	}
+
+	cp := *err
+	if err.errorDetails != nil {
+		details := *err.errorDetails
+		cp.errorDetails = &details
+	}
+	return &cp
+}
+
 // NotNil checks if the app errors is not nil or not
 func (err *AppError) NotNil() bool {
 	return err != nil
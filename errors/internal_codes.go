@@ -20,6 +20,24 @@ var (
 		code: "L0401",
 		link: "https://support.gopherhut.com/docs/error-codes-and-what-they-mean#L0401",
 	}
+
+	// request payload decoding errors
+	MalformedPayload = ErrorCode{
+		code: "D0001",
+		link: "https://support.gopherhut.com/docs/error-codes-and-what-they-mean#D0001",
+	}
+	EmptyPayload = ErrorCode{
+		code: "D0002",
+		link: "https://support.gopherhut.com/docs/error-codes-and-what-they-mean#D0002",
+	}
+	InvalidFieldType = ErrorCode{
+		code: "D0003",
+		link: "https://support.gopherhut.com/docs/error-codes-and-what-they-mean#D0003",
+	}
+	UnknownField = ErrorCode{
+		code: "D0004",
+		link: "https://support.gopherhut.com/docs/error-codes-and-what-they-mean#D0004",
+	}
 )
 
 // Form forms the Details struct for the error code receiver.
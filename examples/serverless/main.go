@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/manigandand/adk/api"
+	"github.com/manigandand/adk/errors"
+	"github.com/manigandand/adk/middleware"
+	"github.com/manigandand/adk/respond"
+	"github.com/manigandand/adk/serverless"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// runs the handlers locally on the fixture event files, no cloud access needed
+//
+//	go run ./examples/serverless -event examples/serverless/events/apigw_v1.json
+//	go run ./examples/serverless -event examples/serverless/events/apigw_v2.json
+func main() {
+	event := flag.String("event", "examples/serverless/events/apigw_v2.json", "API Gateway proxy event file")
+	flag.Parse()
+
+	r := chi.NewRouter()
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+
+	r.Method(http.MethodPost, "/user", api.Handler(CreateUserHandler))
+
+	res, err := serverless.New(r).InvokeFile(context.Background(), *event)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(res))
+}
+
+type createUserReq struct {
+	Email string `json:"email" normalize:"trim,lower"`
+	Name  string `json:"name"`
+}
+
+func (c *createUserReq) Validate() *errors.AppError {
+	if c.Email == "" {
+		return errors.KeyRequired("email")
+	}
+
+	return nil
+}
+
+// CreateUserHandler creates a new users
+func CreateUserHandler(w http.ResponseWriter, r *http.Request) *errors.AppError {
+	var createReq createUserReq
+	if err := api.Decode(r, &createReq); err != nil {
+		return err
+	}
+
+	// respond to the client
+	return respond.Created(w, map[string]interface{}{
+		"message": "user created successfully",
+		"email":   createReq.Email,
+	})
+}
@@ -0,0 +1,561 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// HAR recording defaults, exported so that it can be changed by developers
+var (
+	// HARRedactHeaders are the headers redacted by default
+	HARRedactHeaders = []string{
+		"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key",
+	}
+	// HARRedactFields are the JSON body fields & query params redacted by default
+	HARRedactFields = []string{
+		"password", "token", "secret", "access_token", "refresh_token", "client_secret", "api_key",
+	}
+	// HARMaxBodySize is the max body size recorded per entry, shared by the
+	// request & the response bodies
+	HARMaxBodySize = 128 << 10
+)
+
+// HARRedacted replaces the redacted values
+const HARRedacted = "[REDACTED]"
+
+// HAR 1.2 types, http://www.softwareishard.com/blog/har-12-spec/ ---------------
+
+// HAR is the HAR 1.2 document
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog is the log of the HAR document
+type HARLog struct {
+	Version string      `json:"version"`
+	Creator HARCreator  `json:"creator"`
+	Entries []*HAREntry `json:"entries"`
+}
+
+// HARCreator is the creator of the HAR document
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// NewHAR returns the HAR 1.2 document of the entries
+func NewHAR(entries []*HAREntry) *HAR {
+	return &HAR{
+		Log: HARLog{
+			Version: "1.2",
+			Creator: HARCreator{Name: "adk", Version: "1.0"},
+			Entries: entries,
+		},
+	}
+}
+
+// HAREntry is the recorded request & response
+type HAREntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"` // ms
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+	// RequestID is the request id of the request, custom fields start with "_"
+	RequestID string `json:"_requestId,omitempty"`
+	// RoutePattern is the matched route template. Ex: /user/{id}
+	RoutePattern string `json:"_routePattern,omitempty"`
+}
+
+// HARRequest is the recorded request
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+// HARResponse is the recorded response
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+// HARNameValue is the header, query param or cookie
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARPostData is the request body
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"_encoding,omitempty"` // base64 for the binary bodies
+	Comment  string `json:"comment,omitempty"`
+}
+
+// HARContent is the response body
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// HARTimings is the timings of the entry in ms, -1 is not applicable
+type HARTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// HARRecorder records the sampled requests & the requests matched by the
+// filter in the HAR 1.2 format. The sensitive headers, JSON body fields &
+// query params are redacted and the bodies of the entry are capped at
+// MaxBodySize.
+//
+// EX:
+//
+//	sink, err := middleware.NewRotatingFileSink("/var/log/api/traffic.har.ndjson", 100<<20, 5)
+//	har := middleware.NewHARRecorder(sink)
+//	har.SampleRate = 0.01
+//	har.Filter = func(r *http.Request) bool { return r.Header.Get("X-Debug-Trace") == "1" }
+//
+//	r.Use(middleware.Logger)
+//	r.Use(har.Middleware)
+type HARRecorder struct {
+	Sink HARSink
+	// SampleRate is the ratio of the recorded requests, 0 to 1
+	SampleRate float64
+	// Filter records the matching requests, in addition to the sampled ones
+	Filter        func(r *http.Request) bool
+	RedactHeaders []string
+	RedactFields  []string
+	// MaxBodySize caps the request & the response bodies of the entry in
+	// total, the request body is recorded first as it's read by the handler
+	MaxBodySize int
+
+	// redactPattern is compiled once for the RedactFields
+	redactPattern atomic.Pointer[harPattern]
+}
+
+type harPattern struct {
+	fields string
+	re     *regexp.Regexp
+}
+
+// NewHARRecorder returns the HAR recorder with the default redactions
+func NewHARRecorder(sink HARSink) *HARRecorder {
+	return &HARRecorder{
+		Sink:          sink,
+		RedactHeaders: append([]string(nil), HARRedactHeaders...),
+		RedactFields:  append([]string(nil), HARRedactFields...),
+		MaxBodySize:   HARMaxBodySize,
+	}
+}
+
+func (h *HARRecorder) sampled(r *http.Request) bool {
+	if h.Filter != nil && h.Filter(r) {
+		return true
+	}
+	return h.SampleRate > 0 && rand.Float64() < h.SampleRate
+}
+
+// Middleware records the request & the response. Use it after the Logger,
+// so the request id is recorded. The request body is recorded as it's read
+// by the handler.
+func (h *HARRecorder) Middleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if !h.sampled(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		room := &bodyRoom{}
+		room.n.Store(int64(h.MaxBodySize))
+		reqBody := &capBuffer{room: room}
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = &teeReadCloser{Reader: io.TeeReader(r.Body, reqBody), Closer: r.Body}
+		}
+
+		rec := &harResponseWriter{
+			ResponseWriter: w,
+			body:           &capBuffer{room: room},
+			code:           http.StatusOK,
+		}
+		next.ServeHTTP(rec, r)
+		end := time.Now()
+
+		entry := h.entry(r, rec, reqBody, start, end)
+		if err := h.Sink.Write(entry); err != nil {
+			log.WithField("request_id", entry.RequestID).Warn("middleware: har sink: ", err)
+		}
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+func (h *HARRecorder) entry(r *http.Request, rec *harResponseWriter, reqBody *capBuffer, start, end time.Time) *HAREntry {
+	wait := end
+	if !rec.firstByte.IsZero() {
+		wait = rec.firstByte
+	}
+
+	entry := &HAREntry{
+		StartedDateTime: start,
+		Time:            ms(end.Sub(start)),
+		Timings: HARTimings{
+			Send:    0,
+			Wait:    ms(wait.Sub(start)),
+			Receive: ms(end.Sub(wait)),
+		},
+		RequestID:    RequestID(r.Context()),
+		RoutePattern: RoutePattern(r),
+	}
+	if entry.RequestID == "" {
+		entry.RequestID = r.Header.Get(RequestIDHeader)
+	}
+	entry.Comment = entry.RequestID
+
+	entry.Request = HARRequest{
+		Method:      r.Method,
+		URL:         h.redactURL(r),
+		HTTPVersion: r.Proto,
+		Cookies:     []HARNameValue{},
+		Headers:     h.headers(r.Header),
+		QueryString: h.query(r.URL.Query()),
+		HeadersSize: -1,
+		BodySize:    reqBody.size,
+	}
+	if reqBody.size > 0 {
+		text, encoding, comment := h.body(reqBody, r.Header.Get("Content-Type"))
+		entry.Request.PostData = &HARPostData{
+			MimeType: r.Header.Get("Content-Type"),
+			Text:     text,
+			Encoding: encoding,
+			Comment:  comment,
+		}
+	}
+
+	contentType := rec.Header().Get("Content-Type")
+	text, encoding, comment := h.body(rec.body, contentType)
+	entry.Response = HARResponse{
+		Status:      rec.code,
+		StatusText:  http.StatusText(rec.code),
+		HTTPVersion: r.Proto,
+		Cookies:     []HARNameValue{},
+		Headers:     h.headers(rec.Header()),
+		Content: HARContent{
+			Size:     rec.body.size,
+			MimeType: contentType,
+			Text:     text,
+			Encoding: encoding,
+			Comment:  comment,
+		},
+		RedirectURL: rec.Header().Get("Location"),
+		HeadersSize: -1,
+		BodySize:    rec.body.size,
+	}
+	return entry
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func (h *HARRecorder) redactURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+
+	u := url.URL{
+		Scheme:   scheme,
+		Host:     r.Host,
+		Path:     r.URL.Path,
+		RawPath:  r.URL.RawPath,
+		RawQuery: h.redactQuery(r.URL.Query()).Encode(),
+	}
+	return u.String()
+}
+
+func (h *HARRecorder) redactQuery(query url.Values) url.Values {
+	redacted := make(url.Values, len(query))
+	for key, values := range query {
+		if containsFold(h.RedactFields, key) {
+			values = []string{HARRedacted}
+		}
+		redacted[key] = values
+	}
+	return redacted
+}
+
+func (h *HARRecorder) query(query url.Values) []HARNameValue {
+	nvs := []HARNameValue{}
+	for key, values := range h.redactQuery(query) {
+		for _, value := range values {
+			nvs = append(nvs, HARNameValue{Name: key, Value: value})
+		}
+	}
+	return nvs
+}
+
+func (h *HARRecorder) headers(header http.Header) []HARNameValue {
+	nvs := []HARNameValue{}
+	for key, values := range header {
+		for _, value := range values {
+			if containsFold(h.RedactHeaders, key) {
+				value = HARRedacted
+			}
+			nvs = append(nvs, HARNameValue{Name: key, Value: value})
+		}
+	}
+	return nvs
+}
+
+// body returns the redacted text of the body, the binary bodies are base64 encoded
+func (h *HARRecorder) body(buf *capBuffer, contentType string) (text, encoding, comment string) {
+	data := buf.Bytes()
+	if buf.truncated() {
+		comment = "truncated"
+	}
+
+	if !isTextContentType(contentType) {
+		return base64.StdEncoding.EncodeToString(data), "base64", comment
+	}
+	if isJSONContentType(contentType) {
+		return h.redactJSON(data, buf.truncated()), "", comment
+	}
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") && !buf.truncated() {
+		if form, err := url.ParseQuery(string(data)); err == nil {
+			return h.redactQuery(form).Encode(), "", comment
+		}
+	}
+	return string(data), "", comment
+}
+
+// redactJSON redacts the fields of the JSON body at any depth. The truncated
+// bodies are not valid JSON, their string values are redacted by the pattern.
+func (h *HARRecorder) redactJSON(data []byte, truncated bool) string {
+	if len(h.RedactFields) == 0 {
+		return string(data)
+	}
+
+	var doc interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if truncated || dec.Decode(&doc) != nil {
+		return h.redactJSONText(data)
+	}
+
+	redactValue(doc, h.RedactFields)
+	redacted, err := json.Marshal(doc)
+	if err != nil {
+		return h.redactJSONText(data)
+	}
+	return string(redacted)
+}
+
+func (h *HARRecorder) redactJSONText(data []byte) string {
+	return h.jsonFieldsPattern().ReplaceAllString(string(data), `${1}"`+HARRedacted+`"`)
+}
+
+// jsonFieldsPattern returns the pattern of the RedactFields, it's compiled
+// again only when the fields are changed.
+func (h *HARRecorder) jsonFieldsPattern() *regexp.Regexp {
+	fields := strings.Join(h.RedactFields, "\x00")
+	if p := h.redactPattern.Load(); p != nil && p.fields == fields {
+		return p.re
+	}
+
+	names := make([]string, len(h.RedactFields))
+	for i, field := range h.RedactFields {
+		names[i] = regexp.QuoteMeta(field)
+	}
+	// "password": "..." or "password": 1234 or the truncated value
+	re := regexp.MustCompile(`(?i)("(?:` + strings.Join(names, "|") + `)"\s*:\s*)("(?:[^"\\]|\\.)*("|$)|[^,}\]\s]+)`)
+	h.redactPattern.Store(&harPattern{fields: fields, re: re})
+	return re
+}
+
+func redactValue(v interface{}, fields []string) {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for key, value := range x {
+			if containsFold(fields, key) {
+				x[key] = HARRedacted
+				continue
+			}
+			redactValue(value, fields)
+		}
+	case []interface{}:
+		for _, value := range x {
+			redactValue(value, fields)
+		}
+	}
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func isJSONContentType(contentType string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func isTextContentType(contentType string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	switch {
+	case mediaType == "",
+		strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript", "application/x-www-form-urlencoded":
+		return true
+	}
+	return false
+}
+
+// bodyRoom is the remaining body size of the entry, shared by the request &
+// the response bodies, which could be written concurrently
+type bodyRoom struct {
+	n atomic.Int64
+}
+
+// take takes up to n bytes of the room and returns the taken size
+func (r *bodyRoom) take(n int) int {
+	for {
+		room := r.n.Load()
+		if room <= 0 {
+			return 0
+		}
+		taken := min(int64(n), room)
+		if r.n.CompareAndSwap(room, room-taken) {
+			return int(taken)
+		}
+	}
+}
+
+// capBuffer keeps the bytes fitting in the room and counts the total size
+type capBuffer struct {
+	bytes.Buffer
+	room *bodyRoom
+	size int64
+}
+
+func (b *capBuffer) Write(p []byte) (int, error) {
+	b.size += int64(len(p))
+	if n := b.room.take(len(p)); n > 0 {
+		b.Buffer.Write(p[:n])
+	}
+	return len(p), nil
+}
+
+func (b *capBuffer) truncated() bool {
+	return b.size > int64(b.Len())
+}
+
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// harResponseWriter records the response, up to the max body size
+type harResponseWriter struct {
+	http.ResponseWriter
+	body        *capBuffer
+	code        int
+	wroteHeader bool
+	firstByte   time.Time
+}
+
+// WriteHeader implements http.ResponseWriter
+func (rw *harResponseWriter) WriteHeader(statusCode int) {
+	if !rw.wroteHeader {
+		rw.code = statusCode
+		rw.wroteHeader = true
+		rw.firstByte = time.Now()
+	}
+	rw.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write implements http.ResponseWriter
+func (rw *harResponseWriter) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
+
+// Hijack implements http.Hijacker, the hijacked connections are recorded
+// with the status 101 and without the body.
+func (rw *harResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("middleware: responsewriter doesn't implement http.Hijacker")
+	}
+
+	conn, buf, err := hijacker.Hijack()
+	if err == nil {
+		rw.code = http.StatusSwitchingProtocols
+		rw.wroteHeader = true
+	}
+	return conn, buf, err
+}
+
+// Flush implements http.Flusher
+func (rw *harResponseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the underlying responsewriter, used by http.ResponseController
+func (rw *harResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// HARSink writes the recorded HAR entries, the implementations must be safe
+// for the concurrent use.
+type HARSink interface {
+	Write(entry *HAREntry) error
+}
+
+// HARSinkFunc adapts the func into the HARSink. Ex: to ship the entries to
+// the log pipeline.
+type HARSinkFunc func(entry *HAREntry) error
+
+// Write implements HARSink
+func (fn HARSinkFunc) Write(entry *HAREntry) error {
+	return fn(entry)
+}
+
+// RotatingFileSink writes the HAR entries as the newline delimited JSON, one
+// entry per line. The file is rotated once it reaches MaxSize, the rotated
+// files are renamed as <path>.1, <path>.2 ... and the files beyond MaxBackups
+// are removed. Use NewHAR to export the entries as the HAR 1.2 document.
+type RotatingFileSink struct {
+	Path       string
+	MaxSize    int64
+	MaxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewRotatingFileSink opens the file sink, the entries are appended to the
+// existing file.
+func NewRotatingFileSink(path string, maxSize int64, maxBackups int) (*RotatingFileSink, error) {
+	s := &RotatingFileSink{
+		Path:       path,
+		MaxSize:    maxSize,
+		MaxBackups: maxBackups,
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Write implements HARSink
+func (s *RotatingFileSink) Write(entry *HAREntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return fmt.Errorf("har sink %s is closed", s.Path)
+	}
+	var rotateErr error
+	if s.MaxSize > 0 && s.size > 0 && s.size+int64(len(line)) > s.MaxSize {
+		if rotateErr = s.rotate(); s.file == nil {
+			return rotateErr
+		}
+	}
+
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	return errors.Join(rotateErr, err)
+}
+
+// Close closes the file
+func (s *RotatingFileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+func (s *RotatingFileSink) open() error {
+	f, err := os.OpenFile(s.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	s.file = f
+	s.size = info.Size()
+	return nil
+}
+
+// rotate shifts the backups, <path>.N-1 -> <path>.N, and opens the new file.
+// The current file is reopened if the rotation fails, so the sink keeps
+// writing, beyond the MaxSize.
+func (s *RotatingFileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	s.file = nil
+
+	if err := s.shift(); err != nil {
+		if openErr := s.open(); openErr != nil {
+			return errors.Join(err, openErr)
+		}
+		return err
+	}
+	return s.open()
+}
+
+func (s *RotatingFileSink) shift() error {
+	if s.MaxBackups <= 0 {
+		return os.Remove(s.Path)
+	}
+
+	_ = os.Remove(fmt.Sprintf("%s.%d", s.Path, s.MaxBackups))
+	for i := s.MaxBackups - 1; i > 0; i-- {
+		_ = os.Rename(fmt.Sprintf("%s.%d", s.Path, i), fmt.Sprintf("%s.%d", s.Path, i+1))
+	}
+	return os.Rename(s.Path, s.Path+".1")
+}
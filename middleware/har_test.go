@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHARRecorderMaxBodySize(t *testing.T) {
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write(body)
+		w.Write(body)
+	})
+
+	tests := []struct {
+		name      string
+		body      string
+		wantReq   string
+		wantRes   string
+		truncated bool
+	}{
+		{"fits", "abc", "abc", "abcabc", false},
+		{"response truncated", "abcd", "abcd", "abcdab", true},
+		{"request truncated", strings.Repeat("x", 12), strings.Repeat("x", 10), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var entries []*HAREntry
+			har := NewHARRecorder(HARSinkFunc(func(entry *HAREntry) error {
+				entries = append(entries, entry)
+				return nil
+			}))
+			har.SampleRate = 1
+			har.MaxBodySize = 10
+
+			req := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "text/plain")
+			har.Middleware(echo).ServeHTTP(httptest.NewRecorder(), req)
+
+			entry := entries[0]
+			if got := entry.Request.PostData.Text; got != tt.wantReq {
+				t.Errorf("request body = %q, want %q", got, tt.wantReq)
+			}
+			if got := entry.Response.Content.Text; got != tt.wantRes {
+				t.Errorf("response body = %q, want %q", got, tt.wantRes)
+			}
+			if truncated := entry.Response.Content.Comment == "truncated"; truncated != tt.truncated {
+				t.Errorf("response truncated = %v, want %v", truncated, tt.truncated)
+			}
+			if entry.Response.BodySize != int64(2*len(tt.body)) {
+				t.Errorf("response size = %d, want %d", entry.Response.BodySize, 2*len(tt.body))
+			}
+		})
+	}
+}
+
+func TestNewHARRecorderCopiesDefaults(t *testing.T) {
+	har := NewHARRecorder(nil)
+	har.RedactFields[0] = "changed"
+	har.RedactHeaders = append(har.RedactHeaders[:0], "X-Other")
+
+	if HARRedactFields[0] != "password" || HARRedactHeaders[0] != "Authorization" {
+		t.Errorf("defaults changed: %q, %q", HARRedactFields, HARRedactHeaders)
+	}
+}
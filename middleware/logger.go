@@ -1,9 +1,12 @@
 package middleware
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"time"
 )
@@ -20,6 +23,12 @@ func (c ContextKey) String() string {
 // Exported so that it can be changed by developers
 var RequestIDHeader = "X-Request-Id"
 
+// RoutePattern returns the route template of the request which is logged by
+// the Logger. Ex: /user/{id}. The api package sets the router agnostic api.RoutePattern
+var RoutePattern = func(r *http.Request) string {
+	return ""
+}
+
 // ResponseWriter hijacker, just hijack only status code
 
 type responseWriter struct {
@@ -34,12 +43,52 @@ func (rw *responseWriter) WriteHeader(statusCode int) {
 	rw.ResponseWriter.WriteHeader(statusCode)
 }
 
-func getReqID(r *http.Request) string {
-	reqID, ok := r.Context().Value(RequestIDHeader).(string)
+// Hijack implements http.Hijacker, required by the websocket upgrade
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rw.ResponseWriter.(http.Hijacker)
 	if !ok {
-		return ""
+		return nil, nil, errors.New("middleware: responsewriter doesn't implement http.Hijacker")
+	}
+
+	conn, buf, err := hijacker.Hijack()
+	if err == nil {
+		rw.code = http.StatusSwitchingProtocols
+	}
+	return conn, buf, err
+}
+
+// Flush implements http.Flusher
+func (rw *responseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the underlying responsewriter, used by http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+func getReqID(r *http.Request) string {
+	return RequestID(r.Context())
+}
+
+// RequestID returns the request id of the request, set by the Logger middleware.
+func RequestID(ctx context.Context) string {
+	if reqID, ok := ctx.Value(ContextKey(RequestIDHeader)).(string); ok {
+		return reqID
+	}
+	// keys set as plain string by the callers
+	if reqID, ok := ctx.Value(RequestIDHeader).(string); ok {
+		return reqID
 	}
-	return reqID
+	return ""
+}
+
+// WithRequestID returns the context with the request id, used to carry the
+// request id out of the http requests. Ex: queue messages, background tasks
+func WithRequestID(ctx context.Context, reqID string) context.Context {
+	return context.WithValue(ctx, ContextKey(RequestIDHeader), reqID)
 }
 
 // Logger middlwware logs the request stats post the call.
@@ -55,17 +104,26 @@ func Logger(next http.Handler) http.Handler {
 			reqID = getReqID(r)
 			if reqID == "" {
 				reqID = fmt.Sprintf("%d", time.Now().UnixNano())
-				ctx = context.WithValue(ctx, ContextKey(RequestIDHeader), reqID)
 			}
 		}
+		ctx = WithRequestID(ctx, reqID)
 		start := time.Now()
 		rw := &responseWriter{
 			ResponseWriter: w,
 		}
 
 		// call the next handler
-		next.ServeHTTP(rw, r.WithContext(ctx))
+		req := r.WithContext(ctx)
+		next.ServeHTTP(rw, req)
 
+		// the route is matched by the router while serving the request
+		if pattern := RoutePattern(req); pattern != "" {
+			log.Printf(
+				"[%s] %s [%d] %s?%s %v %s",
+				reqID, r.Method, rw.code, r.URL.Path, r.URL.RawQuery, time.Since(start), pattern,
+			)
+			return
+		}
 		log.Printf(
 			"[%s] %s [%d] %s?%s %v",
 			reqID, r.Method, rw.code, r.URL.Path, r.URL.RawQuery, time.Since(start),
@@ -17,15 +17,7 @@ func Recoverer(next http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if rvr := recover(); rvr != nil {
-				const size = 4096
-
-				buf := make([]byte, size)
-				buf = buf[:runtime.Stack(buf, false)]
-				buffer := string(buf)
-
-				excMessage := fmt.Errorf("%+v", rvr).Error()
-
-				log.Error(fmt.Errorf("[panic-recover] %v \n%v", excMessage, buffer), r.URL.String())
+				LogPanic(rvr, r.URL.String())
 
 				// TODO: Let devloper know the panic
 				// Log into sentry/..
@@ -39,3 +31,22 @@ func Recoverer(next http.Handler) http.Handler {
 
 	return http.HandlerFunc(fn)
 }
+
+// LogPanic logs the recovered panic value with the stack trace, where tells
+// what panicked. Ex: the request url, the queue message or the task name
+//
+// EX:
+//
+//	defer func() {
+//		if rvr := recover(); rvr != nil {
+//			middleware.LogPanic(rvr, "background task "+name)
+//		}
+//	}()
+func LogPanic(rvr interface{}, where string) {
+	const size = 4096
+
+	buf := make([]byte, size)
+	buf = buf[:runtime.Stack(buf, false)]
+
+	log.Error(fmt.Errorf("[panic-recover] %+v \n%s", rvr, buf), where)
+}
@@ -0,0 +1,236 @@
+package pubid
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"hash/crc32"
+	"reflect"
+	"strings"
+
+	"github.com/manigandand/adk/api"
+	"github.com/manigandand/adk/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Separator separates the prefix and the encoded payload. Ex: usr_0f3ka
+const Separator = "_"
+
+// checksumSize is the number of the checksum bytes appended to the payload
+const checksumSize = 2
+
+// Secret obfuscates the payload of the ids, so the sequential ids don't look
+// sequential. Set it once at the startup, changing it invalidates the issued ids.
+// Exported so that it can be changed by developers
+var Secret []byte
+
+// encoding is the lower case crockford base32 alphabet, without i, l, o & u
+var encoding = base32.NewEncoding("0123456789abcdefghjkmnpqrstvwxyz").WithPadding(base32.NoPadding)
+
+// crockford maps the commonly mistyped characters
+var crockford = strings.NewReplacer("i", "1", "l", "1", "o", "0")
+
+// Prefixer gives the prefix of the resource type. The prefix should be short,
+// lower case & unique across the resource types.
+//
+// EX:
+//
+//	type User struct{}
+//
+//	func (User) Prefix() string { return "usr" }
+//
+//	type UserID = pubid.ID[User]
+type Prefixer interface {
+	Prefix() string
+}
+
+// ID is the opaque public id of the resource type P. The internal int64 or
+// ObjectID is encoded with the prefix of P and the checksum, Ex: usr_0f3kaz.
+// The checksum covers the prefix, so the id of the other type is rejected.
+// ID is marshalled as the JSON string and the zero ID as "".
+//
+// EX:
+//
+//	type userResp struct {
+//		ID   UserID `json:"id"`
+//		Name string `json:"name"`
+//	}
+//
+//	res := &userResp{ID: pubid.FromInt64[User](user.ID), Name: user.Name}
+type ID[P Prefixer] struct {
+	payload string
+}
+
+// FromInt64 returns the public id of the int64 id
+func FromInt64[P Prefixer](n int64) ID[P] {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(n))
+
+	// trim the leading zero bytes, keeps the ids of the small numbers short
+	i := 0
+	for i < len(b)-1 && b[i] == 0 {
+		i++
+	}
+	return ID[P]{payload: string(b[i:])}
+}
+
+// FromObjectID returns the public id of the mongo ObjectID
+func FromObjectID[P Prefixer](oid primitive.ObjectID) ID[P] {
+	return ID[P]{payload: string(oid[:])}
+}
+
+// Parse parses the public id of the type P. The id with the other prefix,
+// the invalid checksum or the non canonical encoding returns errors.InvalidKey.
+func Parse[P Prefixer](s string) (ID[P], *errors.AppError) {
+	prefix := prefixOf[P]()
+
+	encoded, ok := strings.CutPrefix(s, prefix+Separator)
+	if !ok || encoded == "" {
+		return ID[P]{}, invalidID(s, prefix, "expected the prefix "+prefix+Separator)
+	}
+
+	encoded = crockford.Replace(strings.ToLower(encoded))
+	b, err := encoding.DecodeString(encoded)
+	if err != nil || len(b) <= checksumSize {
+		return ID[P]{}, invalidID(s, prefix, "invalid encoding")
+	}
+	// the decoder ignores the trailing bits, the other ids of the same payload are rejected
+	if encoding.EncodeToString(b) != encoded {
+		return ID[P]{}, invalidID(s, prefix, "non canonical encoding")
+	}
+
+	payload, sum := b[:len(b)-checksumSize], b[len(b)-checksumSize:]
+	obfuscate(prefix, payload)
+	if string(checksum(prefix, payload)) != string(sum) {
+		return ID[P]{}, invalidID(s, prefix, "checksum mismatch")
+	}
+
+	return ID[P]{payload: string(payload)}, nil
+}
+
+// MustParse is like Parse but panics if the id is invalid. Used for the
+// constant ids, Ex: in the fixtures.
+func MustParse[P Prefixer](s string) ID[P] {
+	id, err := Parse[P](s)
+	if err != nil {
+		panic(err.Error())
+	}
+	return id
+}
+
+// String returns the encoded public id, "" for the zero ID
+func (id ID[P]) String() string {
+	if id.IsZero() {
+		return ""
+	}
+
+	prefix := prefixOf[P]()
+	payload := []byte(id.payload)
+	sum := checksum(prefix, payload)
+	obfuscate(prefix, payload)
+
+	return prefix + Separator + encoding.EncodeToString(append(payload, sum...))
+}
+
+// IsZero reports whether the id is not set
+func (id ID[P]) IsZero() bool {
+	return id.payload == ""
+}
+
+// Int64 returns the int64 id, the public id must be created by FromInt64
+func (id ID[P]) Int64() (int64, *errors.AppError) {
+	if id.IsZero() || len(id.payload) > 8 {
+		return 0, invalidID(id.String(), prefixOf[P](), "not an int64 id")
+	}
+
+	b := make([]byte, 8)
+	copy(b[8-len(id.payload):], id.payload)
+	return int64(binary.BigEndian.Uint64(b)), nil
+}
+
+// ObjectID returns the mongo ObjectID, the public id must be created by FromObjectID
+func (id ID[P]) ObjectID() (primitive.ObjectID, *errors.AppError) {
+	var oid primitive.ObjectID
+	if len(id.payload) != len(oid) {
+		return oid, invalidID(id.String(), prefixOf[P](), "not an ObjectID")
+	}
+
+	copy(oid[:], id.payload)
+	return oid, nil
+}
+
+// MarshalText implements encoding.TextMarshaler, used by encoding/json
+func (id ID[P]) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, used by encoding/json.
+// The error is the *errors.AppError, so api.Decode responds it as it is.
+func (id *ID[P]) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*id = ID[P]{}
+		return nil
+	}
+
+	parsed, err := Parse[P](string(text))
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// Register registers the ID[P] with the api.FormDecoder & the path params
+// decoder, so the ID can be used in the query & path params structs.
+//
+// EX:
+//
+//	func init() {
+//		pubid.Register[User]()
+//	}
+//
+//	type getUserReq struct {
+//		ID UserID `path:"id"`
+//	}
+func Register[P Prefixer]() {
+	api.RegisterConverter(ID[P]{}, func(s string) reflect.Value {
+		id, err := Parse[P](s)
+		if err != nil {
+			return reflect.Value{}
+		}
+		return reflect.ValueOf(id)
+	})
+}
+
+func prefixOf[P Prefixer]() string {
+	var p P
+	return p.Prefix()
+}
+
+func invalidID(s, prefix, reason string) *errors.AppError {
+	return errors.InvalidKey(s, "id").AddDebugf("pubid: invalid %s id: %s", prefix, reason)
+}
+
+// checksum of the prefix & the raw payload
+func checksum(prefix string, payload []byte) []byte {
+	h := crc32.NewIEEE()
+	h.Write([]byte(prefix))
+	h.Write(payload)
+
+	sum := make([]byte, 4)
+	binary.BigEndian.PutUint32(sum, h.Sum32())
+	return sum[:checksumSize]
+}
+
+// obfuscate XORs the payload in place with the key stream of the Secret & the
+// prefix, it's a no-op if the Secret is not set.
+func obfuscate(prefix string, payload []byte) {
+	if len(Secret) == 0 {
+		return
+	}
+
+	key := sha256.Sum256(append(append([]byte{}, Secret...), prefix...))
+	for i := range payload {
+		payload[i] ^= key[i%len(key)]
+	}
+}
@@ -0,0 +1,128 @@
+package pubid
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type user struct{}
+
+func (user) Prefix() string { return "usr" }
+
+type org struct{}
+
+func (org) Prefix() string { return "org" }
+
+func TestInt64RoundTrip(t *testing.T) {
+	for _, secret := range []string{"", "s3cret"} {
+		Secret = []byte(secret)
+		for _, n := range []int64{0, 1, 255, 256, 1 << 40, math.MaxInt64, -1, math.MinInt64} {
+			s := FromInt64[user](n).String()
+			id, err := Parse[user](s)
+			if err != nil {
+				t.Fatalf("Parse(%q) of %d: %v", s, n, err.GetDebug())
+			}
+			if got, err := id.Int64(); err != nil || got != n {
+				t.Errorf("Int64() of %q = %d, %v, want %d", s, got, err, n)
+			}
+		}
+	}
+	Secret = nil
+}
+
+func TestObjectIDRoundTrip(t *testing.T) {
+	oid := primitive.NewObjectID()
+	id, err := Parse[user](FromObjectID[user](oid).String())
+	if err != nil {
+		t.Fatal(err.GetDebug())
+	}
+	if got, err := id.ObjectID(); err != nil || got != oid {
+		t.Errorf("ObjectID() = %s, %v, want %s", got.Hex(), err, oid.Hex())
+	}
+	if _, err := id.Int64(); err == nil {
+		t.Error("Int64() of the ObjectID id = nil error")
+	}
+}
+
+func TestParseMistyped(t *testing.T) {
+	s := FromInt64[user](1<<40 + 10).String()
+	encoded := strings.TrimPrefix(s, "usr_")
+
+	typed := "usr_" + strings.NewReplacer("1", "L", "0", "O").Replace(strings.ToUpper(encoded))
+	id, err := Parse[user](typed)
+	if err != nil {
+		t.Fatalf("Parse(%q) of %q: %v", typed, s, err.GetDebug())
+	}
+	if id.String() != s {
+		t.Errorf("String() = %q, want %q", id.String(), s)
+	}
+}
+
+const alphabet = "0123456789abcdefghjkmnpqrstvwxyz"
+
+func TestParseInvalid(t *testing.T) {
+	s := FromInt64[user](42).String()
+	encoded := strings.TrimPrefix(s, "usr_")
+
+	// the last char carries the unused trailing bits, flip them
+	last := strings.IndexByte(alphabet, encoded[len(encoded)-1])
+	trailing := encoded[:len(encoded)-1] + string(alphabet[last^1])
+
+	// flip the first payload char
+	first := strings.IndexByte(alphabet, encoded[0])
+	corrupt := string(alphabet[(first+1)%32]) + encoded[1:]
+
+	tests := []struct {
+		name string
+		s    string
+		want string
+	}{
+		{"wrong prefix", FromInt64[org](42).String(), "expected the prefix usr_"},
+		{"wrong prefix with the same payload", "org_" + encoded, "expected the prefix usr_"},
+		{"no prefix", encoded, "expected the prefix usr_"},
+		{"empty payload", "usr_", "expected the prefix usr_"},
+		{"invalid char", "usr_" + encoded + "u", "invalid encoding"},
+		{"too short", "usr_00", "invalid encoding"},
+		{"checksum mismatch", "usr_" + corrupt, "checksum mismatch"},
+		{"trailing bits", "usr_" + trailing, "non canonical encoding"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse[user](tt.s)
+			if err == nil {
+				t.Fatalf("Parse(%q) = nil error", tt.s)
+			}
+			if debug := fmt.Sprint(err.GetDebug()); !strings.Contains(debug, tt.want) {
+				t.Errorf("Parse(%q) debug = %q, want %q", tt.s, debug, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSON(t *testing.T) {
+	type resp struct {
+		ID    ID[user] `json:"id"`
+		Empty ID[user] `json:"empty"`
+	}
+
+	data, err := json.Marshal(resp{ID: FromInt64[user](7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got resp
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := got.ID.Int64(); n != 7 || !got.Empty.IsZero() {
+		t.Errorf("Unmarshal(%s) = %+v", data, got)
+	}
+
+	if err := json.Unmarshal([]byte(`{"id": "`+FromInt64[org](7).String()+`"}`), &got); err == nil {
+		t.Error("Unmarshal of the org id = nil error")
+	}
+}
@@ -0,0 +1,162 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/manigandand/adk/errors"
+	"github.com/manigandand/adk/middleware"
+	log "github.com/sirupsen/logrus"
+)
+
+// Broker is the message broker, Ex: MemoryBroker, SQS, Pub/Sub, RabbitMQ
+type Broker interface {
+	Publish(ctx context.Context, msg *Message) error
+	// Consume returns the deliveries of the topic until the ctx is done
+	Consume(ctx context.Context, topic string) (<-chan Delivery, error)
+}
+
+// Delivery is the delivered message, exactly one of Ack, Retry or
+// DeadLetter is called per delivery.
+type Delivery interface {
+	Message() *Message
+	Ack() error
+	// Retry redelivers the message after the delay
+	Retry(delay time.Duration) error
+	// DeadLetter moves the message to the dead letter queue
+	DeadLetter(err *errors.AppError) error
+}
+
+// Consumer defaults, exported so that it can be changed by developers
+var (
+	DefaultConcurrency = 1
+	DefaultMaxAttempts = 5
+	DefaultBackoff     = ExponentialBackoff(time.Second, 5*time.Minute)
+)
+
+// Consumer consumes the messages of the topic with the handler. The messages
+// which keep failing with the retryable errors are dead-lettered after
+// MaxAttempts.
+//
+// EX:
+//
+//	consumer := queue.NewConsumer(broker, "user.created", queue.Func(sendWelcomeMail))
+//	consumer.Concurrency = 8
+//	go consumer.Run(ctx)
+type Consumer struct {
+	Broker      Broker
+	Topic       string
+	Handler     Handler
+	Concurrency int
+	MaxAttempts int
+	Backoff     Backoff
+	// Decide decides the outcome of the handler error, defaults to Decide
+	Decide func(err *errors.AppError) Decision
+}
+
+// NewConsumer returns the consumer with the defaults
+func NewConsumer(broker Broker, topic string, h Handler) *Consumer {
+	return &Consumer{
+		Broker:      broker,
+		Topic:       topic,
+		Handler:     h,
+		Concurrency: DefaultConcurrency,
+		MaxAttempts: DefaultMaxAttempts,
+		Backoff:     DefaultBackoff,
+		Decide:      Decide,
+	}
+}
+
+// Run consumes the messages until the ctx is done, then waits for the
+// in-flight messages to finish.
+func (c *Consumer) Run(ctx context.Context) error {
+	deliveries, err := c.Broker.Consume(ctx, c.Topic)
+	if err != nil {
+		return err
+	}
+
+	concurrency := c.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range deliveries {
+				// the in-flight message is finished, even if ctx is done
+				c.Process(context.WithoutCancel(ctx), d)
+			}
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// Process handles the delivery and acks, retries or dead-letters it
+func (c *Consumer) Process(ctx context.Context, d Delivery) Decision {
+	msg := d.Message()
+	if reqID := msg.Header(middleware.RequestIDHeader); reqID != "" {
+		ctx = middleware.WithRequestID(ctx, reqID)
+	}
+
+	start := time.Now()
+	appErr := c.handle(ctx, msg)
+
+	decide := c.Decide
+	if decide == nil {
+		decide = Decide
+	}
+	decision := decide(appErr)
+	attempt := msg.Attempt
+	if attempt < 1 {
+		attempt = 1
+	}
+	if decision == Retry && c.MaxAttempts > 0 && attempt >= c.MaxAttempts {
+		decision = DeadLetter
+	}
+
+	var err error
+	switch decision {
+	case Ack:
+		err = d.Ack()
+	case Retry:
+		backoff := c.Backoff
+		if backoff == nil {
+			backoff = DefaultBackoff
+		}
+		err = d.Retry(backoff(attempt))
+	case DeadLetter:
+		err = d.DeadLetter(appErr)
+	}
+
+	status := 200
+	if appErr != nil {
+		status = appErr.GetStatus()
+		appErr.Log()
+	}
+	log.Printf("[%s] %s [%d] %s attempt %d %v %s",
+		msg.Header(middleware.RequestIDHeader), msg.Topic, status, msg.ID, msg.Attempt, time.Since(start), decision,
+	)
+	if err != nil {
+		log.WithField("request_id", msg.Header(middleware.RequestIDHeader)).
+			Errorf("queue: %s message %s: %v", decision, msg.ID, err)
+	}
+	return decision
+}
+
+// handle runs the handler, the panics are recovered & logged as the
+// middleware.Recoverer and fail with 500, so the message is retried.
+func (c *Consumer) handle(ctx context.Context, msg *Message) (appErr *errors.AppError) {
+	defer func() {
+		if rvr := recover(); rvr != nil {
+			middleware.LogPanic(rvr, msg.Topic+" "+msg.ID)
+			appErr = errors.InternalServerStd().WithNoLog()
+		}
+	}()
+
+	return c.Handler(ctx, msg)
+}
@@ -0,0 +1,228 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/manigandand/adk/errors"
+)
+
+// MemoryBufferSize is the buffer size of the in-memory topics, Publish blocks
+// once the buffer is full. Exported so that it can be changed by developers
+var MemoryBufferSize = 1024
+
+// DeadLetterMessage is the dead-lettered message and its error
+type DeadLetterMessage struct {
+	Message *Message
+	Err     *errors.AppError
+}
+
+// MemoryBroker is the in-memory broker, used in the tests & the local
+// development. The retries are redelivered after the delay.
+//
+// EX:
+//
+//	broker := queue.NewMemoryBroker()
+//	go queue.NewConsumer(broker, "user.created", handler).Run(ctx)
+//
+//	broker.Publish(ctx, &queue.Message{Topic: "user.created", Body: body})
+//	broker.WaitIdle(ctx)
+//	dead := broker.DeadLetters("user.created")
+type MemoryBroker struct {
+	mu     sync.Mutex
+	topics map[string]chan *Message
+	dead   map[string][]*DeadLetterMessage
+	acked  map[string]int
+	seq    atomic.Int64
+
+	// pending is the number of the published messages which are not acked
+	// or dead-lettered yet
+	pending int
+	idle    chan struct{}
+}
+
+// NewMemoryBroker returns the in-memory broker
+func NewMemoryBroker() *MemoryBroker {
+	idle := make(chan struct{})
+	close(idle)
+	return &MemoryBroker{
+		topics: map[string]chan *Message{},
+		dead:   map[string][]*DeadLetterMessage{},
+		acked:  map[string]int{},
+		idle:   idle,
+	}
+}
+
+func (b *MemoryBroker) topic(name string) chan *Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch, ok := b.topics[name]
+	if !ok {
+		ch = make(chan *Message, MemoryBufferSize)
+		b.topics[name] = ch
+	}
+	return ch
+}
+
+// Publish implements Broker
+func (b *MemoryBroker) Publish(ctx context.Context, msg *Message) error {
+	if msg.Topic == "" {
+		return fmt.Errorf("queue: message without the topic")
+	}
+	if msg.ID == "" {
+		msg.ID = fmt.Sprintf("%d", b.seq.Add(1))
+	}
+	if msg.PublishedAt.IsZero() {
+		msg.PublishedAt = time.Now()
+	}
+	msg.Attempt = 0
+
+	b.addPending(1)
+	select {
+	case b.topic(msg.Topic) <- msg:
+		return nil
+	case <-ctx.Done():
+		b.addPending(-1)
+		return ctx.Err()
+	}
+}
+
+// Consume implements Broker. The consumers of the same topic share the
+// messages, the channel is closed when the ctx is done.
+func (b *MemoryBroker) Consume(ctx context.Context, topic string) (<-chan Delivery, error) {
+	messages := b.topic(topic)
+	deliveries := make(chan Delivery)
+
+	go func() {
+		defer close(deliveries)
+		for {
+			select {
+			case msg := <-messages:
+				// a copy per attempt, so the handlers can't race with the redelivery
+				m := *msg
+				m.Attempt++
+				d := &memoryDelivery{broker: b, msg: &m}
+				select {
+				case deliveries <- d:
+				case <-ctx.Done():
+					b.requeue(msg, 0)
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return deliveries, nil
+}
+
+// DeadLetters returns the dead-lettered messages of the topic
+func (b *MemoryBroker) DeadLetters(topic string) []*DeadLetterMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]*DeadLetterMessage(nil), b.dead[topic]...)
+}
+
+// Acked returns the number of the acked messages of the topic
+func (b *MemoryBroker) Acked(topic string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.acked[topic]
+}
+
+// WaitIdle waits until all the published messages are acked or dead-lettered,
+// including the scheduled retries.
+func (b *MemoryBroker) WaitIdle(ctx context.Context) error {
+	b.mu.Lock()
+	idle := b.idle
+	b.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *MemoryBroker) addPending(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.pending == 0 && n > 0 {
+		b.idle = make(chan struct{})
+	}
+	b.pending += n
+	if b.pending == 0 {
+		close(b.idle)
+	}
+}
+
+// requeue redelivers the message after the delay, the attempt is
+// incremented on the delivery.
+func (b *MemoryBroker) requeue(msg *Message, delay time.Duration) {
+	send := func() {
+		b.topic(msg.Topic) <- msg
+	}
+	if delay <= 0 {
+		go send()
+		return
+	}
+	time.AfterFunc(delay, send)
+}
+
+type memoryDelivery struct {
+	broker *MemoryBroker
+	msg    *Message
+	done   atomic.Bool
+}
+
+func (d *memoryDelivery) Message() *Message {
+	return d.msg
+}
+
+func (d *memoryDelivery) settle() error {
+	if !d.done.CompareAndSwap(false, true) {
+		return fmt.Errorf("queue: message %s is already settled", d.msg.ID)
+	}
+	return nil
+}
+
+func (d *memoryDelivery) Ack() error {
+	if err := d.settle(); err != nil {
+		return err
+	}
+
+	d.broker.mu.Lock()
+	d.broker.acked[d.msg.Topic]++
+	d.broker.mu.Unlock()
+	d.broker.addPending(-1)
+	return nil
+}
+
+func (d *memoryDelivery) Retry(delay time.Duration) error {
+	if err := d.settle(); err != nil {
+		return err
+	}
+
+	d.broker.requeue(d.msg, delay)
+	return nil
+}
+
+func (d *memoryDelivery) DeadLetter(err *errors.AppError) error {
+	if err := d.settle(); err != nil {
+		return err
+	}
+
+	d.broker.mu.Lock()
+	d.broker.dead[d.msg.Topic] = append(d.broker.dead[d.msg.Topic], &DeadLetterMessage{Message: d.msg, Err: err})
+	d.broker.mu.Unlock()
+	d.broker.addPending(-1)
+	return nil
+}
@@ -0,0 +1,148 @@
+// Package queue runs the message handlers with the same semantics as the
+// api.Handler. The handlers return the *errors.AppError, the payloads are
+// decoded by api.DecodeJSON with the normalize tags & the Validate hook and
+// the panics are recovered. The status of the error decides whether the
+// message is acked, retried with the backoff or dead-lettered.
+package queue
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/manigandand/adk/api"
+	"github.com/manigandand/adk/errors"
+)
+
+// Message is the queue message
+type Message struct {
+	ID    string `json:"id"`
+	Topic string `json:"topic"`
+	Body  []byte `json:"body"`
+	// Headers are the message attributes, the request id is carried in the
+	// middleware.RequestIDHeader
+	Headers map[string]string `json:"headers,omitempty"`
+	// Attempt is the delivery attempt, starts from 1
+	Attempt     int       `json:"attempt"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// Header returns the header of the message
+func (m *Message) Header(key string) string {
+	return m.Headers[key]
+}
+
+// SetHeader sets the header of the message
+func (m *Message) SetHeader(key, value string) {
+	if m.Headers == nil {
+		m.Headers = map[string]string{}
+	}
+	m.Headers[key] = value
+}
+
+// Handler custom message handler, the returned error decides the outcome of
+// the message. See Decide.
+type Handler func(ctx context.Context, msg *Message) *errors.AppError
+
+// Func returns the Handler which decodes the message body into T using
+// api.DecodeJSON, so the normalize tags & the custom validator interfaces are
+// applied as in api.Decode. The invalid payloads are dead-lettered, as they
+// fail with 400.
+//
+// EX:
+//
+//	consumer := queue.NewConsumer(broker, "user.created", queue.Func(func(ctx context.Context, msg *queue.Message, req *createUserReq) *errors.AppError {
+//		return sendWelcomeMail(ctx, req.Email)
+//	}))
+func Func[T any](fn func(ctx context.Context, msg *Message, payload *T) *errors.AppError) Handler {
+	return func(ctx context.Context, msg *Message) *errors.AppError {
+		payload := new(T)
+		if err := api.DecodeJSON(ctx, msg.Body, payload); err != nil {
+			return err
+		}
+		return fn(ctx, msg, payload)
+	}
+}
+
+// Decision is the outcome of the handled message
+type Decision int
+
+// Decisions
+const (
+	Ack Decision = iota
+	Retry
+	DeadLetter
+)
+
+func (d Decision) String() string {
+	switch d {
+	case Ack:
+		return "ack"
+	case Retry:
+		return "retry"
+	case DeadLetter:
+		return "dead-letter"
+	}
+	return "unknown"
+}
+
+// RetryStatuses are the 4XX statuses retried, the 5XX statuses are always
+// retried. Exported so that it can be changed by developers
+var RetryStatuses = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooEarly,
+	http.StatusTooManyRequests,
+}
+
+// Decide returns the decision of the error. The nil error is acked, the 5XX
+// & the RetryStatuses are retried and the rest of the errors, Ex: invalid
+// payloads, are dead-lettered as the retry wouldn't succeed.
+func Decide(err *errors.AppError) Decision {
+	if err == nil {
+		return Ack
+	}
+
+	status := err.GetStatus()
+	if status >= http.StatusInternalServerError {
+		return Retry
+	}
+	for _, s := range RetryStatuses {
+		if s == status {
+			return Retry
+		}
+	}
+	if status < http.StatusBadRequest {
+		return Ack
+	}
+	return DeadLetter
+}
+
+// Backoff returns the delay before the attempt is retried, attempt starts from 1
+type Backoff func(attempt int) time.Duration
+
+// ExponentialBackoff doubles the delay from base on every attempt, up to max,
+// with the full jitter.
+func ExponentialBackoff(base, max time.Duration) Backoff {
+	return func(attempt int) time.Duration {
+		// the brokers which don't count the deliveries
+		if attempt < 1 {
+			attempt = 1
+		}
+
+		delay := max
+		if attempt < 32 {
+			if d := base << (attempt - 1); d > 0 && d < max {
+				delay = d
+			}
+		}
+		return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+	}
+}
+
+// ConstantBackoff retries after the same delay
+func ConstantBackoff(delay time.Duration) Backoff {
+	return func(int) time.Duration {
+		return delay
+	}
+}
@@ -0,0 +1,189 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/manigandand/adk/middleware"
+)
+
+// Diff is the difference between the recorded & the replayed response.
+// Path is "status", "header.<key>" or the JSON path of the body, Ex: data[0].email
+type Diff struct {
+	Path string      `json:"path"`
+	Want interface{} `json:"want"`
+	Got  interface{} `json:"got"`
+}
+
+func (d Diff) String() string {
+	return fmt.Sprintf("%s: want %s, got %s", d.Path, show(d.Want), show(d.Got))
+}
+
+// missing is the value of the absent field
+type missing struct{}
+
+// MarshalJSON implements json.Marshaler
+func (missing) MarshalJSON() ([]byte, error) {
+	return []byte(`"<missing>"`), nil
+}
+
+func show(v interface{}) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+
+	data := bytes.TrimSpace(buf.Bytes())
+	if len(data) > 120 {
+		return string(data[:120]) + "..."
+	}
+	return string(data)
+}
+
+// diffBody compares the JSON bodies by value, the other bodies byte by byte
+func diffBody(want, got []byte, ignore []string) []Diff {
+	wantDoc, wantErr := decodeJSON(want)
+	gotDoc, gotErr := decodeJSON(got)
+	if wantErr != nil || gotErr != nil {
+		if !bytes.Equal(bytes.TrimSpace(want), bytes.TrimSpace(got)) {
+			return []Diff{{Path: "body", Want: string(want), Got: string(got)}}
+		}
+		return nil
+	}
+
+	var diffs []Diff
+	diffValue(nil, wantDoc, gotDoc, ignore, &diffs)
+	return diffs
+}
+
+func decodeJSON(data []byte) (interface{}, error) {
+	var doc interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func diffValue(path []string, want, got interface{}, ignore []string, diffs *[]Diff) {
+	if ignored(path, ignore) {
+		return
+	}
+	// the redacted values are not known
+	if want == middleware.HARRedacted {
+		return
+	}
+
+	switch w := want.(type) {
+	case map[string]interface{}:
+		g, ok := got.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for _, key := range unionKeys(w, g) {
+			wv, wok := w[key]
+			gv, gok := g[key]
+			if !wok {
+				wv = missing{}
+			}
+			if !gok {
+				gv = missing{}
+			}
+			diffValue(append(path, key), wv, gv, ignore, diffs)
+		}
+		return
+	case []interface{}:
+		g, ok := got.([]interface{})
+		if !ok || len(g) != len(w) {
+			break
+		}
+		for i := range w {
+			diffValue(append(path, strconv.Itoa(i)), w[i], g[i], ignore, diffs)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(want, got) {
+		*diffs = append(*diffs, Diff{Path: "body" + formatPath(path), Want: want, Got: got})
+	}
+}
+
+func unionKeys(a, b map[string]interface{}) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ignored reports whether the path matches the ignored fields. The field name
+// without the dots matches the last key at any depth.
+func ignored(path []string, ignore []string) bool {
+	if len(path) == 0 {
+		return false
+	}
+
+	for _, field := range ignore {
+		tokens := parsePath(field)
+		if len(tokens) == 1 && tokens[0] == path[len(path)-1] {
+			return true
+		}
+		if matchPath(tokens, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePath splits the path into the keys & the indexes.
+// Ex: "data[0].email" -> ["data", "0", "email"]
+func parsePath(path string) []string {
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+
+	var tokens []string
+	for _, token := range strings.Split(path, ".") {
+		if token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+func matchPath(tokens, path []string) bool {
+	if len(tokens) != len(path) {
+		return false
+	}
+	for i, token := range tokens {
+		if token != "*" && token != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// formatPath formats the path, Ex: ["data", "0", "email"] -> .data[0].email
+func formatPath(path []string) string {
+	var b strings.Builder
+	for _, token := range path {
+		if _, err := strconv.Atoi(token); err == nil {
+			b.WriteString("[" + token + "]")
+			continue
+		}
+		b.WriteString("." + token)
+	}
+	return b.String()
+}
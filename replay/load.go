@@ -0,0 +1,63 @@
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/manigandand/adk/middleware"
+)
+
+// Load reads the recorded entries of the files, the HAR 1.2 documents or the
+// newline delimited entries written by middleware.RotatingFileSink.
+//
+// EX:
+//
+//	entries, err := replay.Load("traffic.har.ndjson.2", "traffic.har.ndjson.1", "traffic.har.ndjson")
+func Load(paths ...string) ([]*middleware.HAREntry, error) {
+	var entries []*middleware.HAREntry
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		fileEntries, err := Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		entries = append(entries, fileEntries...)
+	}
+	return entries, nil
+}
+
+// Parse parses the HAR document or the newline delimited entries
+func Parse(data []byte) ([]*middleware.HAREntry, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var har middleware.HAR
+	if err := json.Unmarshal(data, &har); err == nil && har.Log.Version != "" {
+		return har.Log.Entries, nil
+	}
+
+	var entries []*middleware.HAREntry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64<<10), 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		entry := &middleware.HAREntry{}
+		if err := json.Unmarshal(text, entry); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,300 @@
+// Package replay replays the recorded traffic, Ex: by middleware.HARRecorder,
+// against the handlers or the locally started server and reports the status
+// & the body diffs per endpoint. The volatile fields, Ex: ids & timestamps,
+// are ignored.
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/manigandand/adk/middleware"
+)
+
+// VolatileFields are the body fields ignored by default, exported so that it
+// can be changed by developers
+var VolatileFields = []string{
+	"created_at", "updated_at", "deleted_at", "timestamp", "request_id",
+}
+
+// skipHeaders are not replayed, they're set by the transport
+var skipHeaders = []string{
+	"Host", "Content-Length", "Connection", "Accept-Encoding", "Transfer-Encoding",
+}
+
+// Replayer replays the entries against the Handler, or the server at BaseURL
+// when the Handler is nil. The entries are replayed in order, so the stateful
+// flows, Ex: create & get, work against the fresh store.
+//
+// EX:
+//
+//	entries, err := replay.Load("testdata/traffic.har")
+//	rp := replay.New(router)
+//	rp.Ignore = append(rp.Ignore, "id", "data[*].token")
+//	rp.Header.Set("Authorization", "Bearer "+testToken)
+//	rp.Body["password"] = testPassword
+//
+//	report := rp.Run(ctx, entries)
+//	if report.Failed() {
+//		report.Write(os.Stdout)
+//	}
+type Replayer struct {
+	Handler http.Handler
+	BaseURL string
+	Client  *http.Client
+	// Header is set on the replayed requests, Ex: the credentials of the
+	// redacted Authorization header
+	Header http.Header
+	// Query is set on the replayed requests, Ex: the redacted api_key param.
+	// The redacted query params are not replayed.
+	Query url.Values
+	// Body is set on the fields of the replayed JSON bodies, Ex: the redacted
+	// password. The field name matches the key at any depth, as the redaction.
+	Body map[string]interface{}
+	// Ignore are the body fields ignored in the comparison. The field name
+	// matches the key at any depth, the path matches the exact location and
+	// the wildcard "*" matches all the array elements or object values.
+	// Ex: "created_at", "data[*].id"
+	Ignore []string
+	// CompareHeaders are the response headers compared, Ex: Content-Type
+	CompareHeaders []string
+}
+
+// New returns the replayer of the handler, with the default volatile fields
+func New(h http.Handler) *Replayer {
+	return &Replayer{
+		Handler:        h,
+		Header:         http.Header{},
+		Query:          url.Values{},
+		Body:           map[string]interface{}{},
+		Ignore:         append([]string(nil), VolatileFields...),
+		CompareHeaders: []string{"Content-Type"},
+	}
+}
+
+// NewRemote returns the replayer of the server at the base url, Ex: http://localhost:3000
+func NewRemote(baseURL string) *Replayer {
+	rp := New(nil)
+	rp.BaseURL = strings.TrimSuffix(baseURL, "/")
+	rp.Client = http.DefaultClient
+	return rp
+}
+
+// Run replays the entries and returns the report
+func (rp *Replayer) Run(ctx context.Context, entries []*middleware.HAREntry) *Report {
+	report := newReport()
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			break
+		}
+		report.add(entry, rp.replay(ctx, entry))
+	}
+	return report
+}
+
+// replay replays the entry and compares the response with the recorded one
+func (rp *Replayer) replay(ctx context.Context, entry *middleware.HAREntry) *Result {
+	result := &Result{
+		RequestID: entry.RequestID,
+		Method:    entry.Request.Method,
+		URL:       entry.Request.URL,
+		Want:      entry.Response.Status,
+	}
+	if entry.Request.PostData != nil && entry.Request.PostData.Comment == "truncated" {
+		result.Skipped = "the recorded request body is truncated"
+		return result
+	}
+
+	req, err := rp.request(ctx, entry)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	status, header, body, err := rp.do(req)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Got = status
+	if status != entry.Response.Status {
+		result.Diffs = append(result.Diffs, Diff{Path: "status", Want: entry.Response.Status, Got: status})
+	}
+	for _, key := range rp.CompareHeaders {
+		want, got := headerValue(entry.Response.Headers, key), header.Get(key)
+		if want != got {
+			result.Diffs = append(result.Diffs, Diff{Path: "header." + key, Want: want, Got: got})
+		}
+	}
+
+	content := entry.Response.Content
+	if content.Comment == "truncated" {
+		return result
+	}
+	want := []byte(content.Text)
+	if content.Encoding == "base64" {
+		if want, err = base64.StdEncoding.DecodeString(content.Text); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+	}
+	result.Diffs = append(result.Diffs, diffBody(want, body, rp.Ignore)...)
+	return result
+}
+
+func (rp *Replayer) request(ctx context.Context, entry *middleware.HAREntry) (*http.Request, error) {
+	u, err := url.Parse(entry.Request.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.Reader = http.NoBody
+	if data := entry.Request.PostData; data != nil {
+		raw := []byte(data.Text)
+		if data.Encoding == "base64" {
+			if raw, err = base64.StdEncoding.DecodeString(data.Text); err != nil {
+				return nil, err
+			}
+		}
+		if raw, err = rp.body(raw, data.MimeType); err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(raw)
+	}
+
+	query := u.Query()
+	for key, values := range query {
+		if containsValue(values, middleware.HARRedacted) {
+			delete(query, key)
+		}
+	}
+	for key, values := range rp.Query {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+
+	target := rp.BaseURL + u.RequestURI()
+	req, err := http.NewRequestWithContext(ctx, entry.Request.Method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if rp.Handler != nil {
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Host = u.Host
+	}
+
+	for _, h := range entry.Request.Headers {
+		if h.Value == middleware.HARRedacted || containsFold(skipHeaders, h.Name) {
+			continue
+		}
+		req.Header.Add(h.Name, h.Value)
+	}
+	for key, values := range rp.Header {
+		req.Header[http.CanonicalHeaderKey(key)] = values
+	}
+	return req, nil
+}
+
+// body sets the Body fields on the JSON request body
+func (rp *Replayer) body(raw []byte, mimeType string) ([]byte, error) {
+	if len(rp.Body) == 0 || !strings.Contains(mimeType, "json") {
+		return raw, nil
+	}
+
+	doc, err := decodeJSON(raw)
+	if err != nil {
+		return raw, nil
+	}
+	if !rp.setBody(doc) {
+		return raw, nil
+	}
+	return json.Marshal(doc)
+}
+
+// setBody sets the Body fields at any depth and reports whether any is set
+func (rp *Replayer) setBody(v interface{}) bool {
+	set := false
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for key, value := range x {
+			if field, ok := rp.bodyField(key); ok {
+				x[key], set = field, true
+				continue
+			}
+			set = rp.setBody(value) || set
+		}
+	case []interface{}:
+		for _, value := range x {
+			set = rp.setBody(value) || set
+		}
+	}
+	return set
+}
+
+func (rp *Replayer) bodyField(key string) (interface{}, bool) {
+	if value, ok := rp.Body[key]; ok {
+		return value, true
+	}
+	for field, value := range rp.Body {
+		if strings.EqualFold(field, key) {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+func (rp *Replayer) do(req *http.Request) (int, http.Header, []byte, error) {
+	if rp.Handler != nil {
+		rec := httptest.NewRecorder()
+		rp.Handler.ServeHTTP(rec, req)
+		return rec.Code, rec.Header(), rec.Body.Bytes(), nil
+	}
+
+	client := rp.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	return res.StatusCode, res.Header, body, err
+}
+
+func headerValue(headers []middleware.HARNameValue, key string) string {
+	for _, h := range headers {
+		if strings.EqualFold(h.Name, key) {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+func containsValue(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
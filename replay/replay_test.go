@@ -0,0 +1,91 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/manigandand/adk/middleware"
+)
+
+// loginHandler accepts the nested credentials.password "secret"
+func loginHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Credentials struct {
+			Password string `json:"password"`
+		} `json:"credentials"`
+		Attempts int `json:"attempts"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Credentials.Password != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"attempts": body.Attempts})
+}
+
+func loginEntry(mimeType, text string) *middleware.HAREntry {
+	return &middleware.HAREntry{
+		Request: middleware.HARRequest{
+			Method:   http.MethodPost,
+			URL:      "http://api.example.com/login",
+			PostData: &middleware.HARPostData{MimeType: mimeType, Text: text},
+		},
+		Response: middleware.HARResponse{
+			Status:  http.StatusOK,
+			Headers: []middleware.HARNameValue{{Name: "Content-Type", Value: "application/json"}},
+			Content: middleware.HARContent{MimeType: "application/json", Text: `{"attempts":3}` + "\n"},
+		},
+	}
+}
+
+func TestReplayBody(t *testing.T) {
+	redacted := `{"credentials": {"Password": "` + middleware.HARRedacted + `"}, "attempts": 3}`
+
+	tests := []struct {
+		name   string
+		entry  *middleware.HAREntry
+		body   map[string]interface{}
+		failed bool
+	}{
+		{"redacted", loginEntry("application/json", redacted), nil, true},
+		{"substituted", loginEntry("application/json", redacted), map[string]interface{}{"password": "secret"}, false},
+		{"not json", loginEntry("text/plain", redacted), map[string]interface{}{"password": "secret"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rp := New(http.HandlerFunc(loginHandler))
+			for key, value := range tt.body {
+				rp.Body[key] = value
+			}
+			report := rp.Run(context.Background(), []*middleware.HAREntry{tt.entry})
+			if report.Failed() != tt.failed {
+				var out strings.Builder
+				report.Write(&out)
+				t.Errorf("Failed() = %v, want %v\n%s", report.Failed(), tt.failed, out.String())
+			}
+		})
+	}
+}
+
+func TestReplayerBodyKeepsOtherFields(t *testing.T) {
+	rp := New(nil)
+	rp.Body["token"] = "t"
+
+	raw := []byte(`{"items": [{"token": "[REDACTED]", "n": 12345678901234567890}], "name": "a"}`)
+	got, err := rp.body(raw, "application/json; charset=utf-8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"items":[{"n":12345678901234567890,"token":"t"}],"name":"a"}`; string(got) != want {
+		t.Errorf("body = %s, want %s", got, want)
+	}
+
+	// unchanged when no field is set
+	raw = []byte(`{"name": "a"}`)
+	if got, _ := rp.body(raw, "application/json"); string(got) != string(raw) {
+		t.Errorf("body = %s, want %s", got, raw)
+	}
+}
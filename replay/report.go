@@ -0,0 +1,130 @@
+package replay
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+
+	"github.com/manigandand/adk/middleware"
+)
+
+// Result is the result of the replayed entry
+type Result struct {
+	RequestID string `json:"request_id,omitempty"`
+	Method    string `json:"method"`
+	URL       string `json:"url"`
+	Want      int    `json:"want_status"`
+	Got       int    `json:"got_status"`
+	Diffs     []Diff `json:"diffs,omitempty"`
+	Skipped   string `json:"skipped,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Passed reports whether the response matches the recorded one
+func (r *Result) Passed() bool {
+	return r.Error == "" && r.Skipped == "" && len(r.Diffs) == 0
+}
+
+// Endpoint is the results of the endpoint, Ex: POST /user/{id}
+type Endpoint struct {
+	Name    string    `json:"name"`
+	Passed  int       `json:"passed"`
+	Failed  int       `json:"failed"`
+	Skipped int       `json:"skipped"`
+	Results []*Result `json:"results"`
+}
+
+// Report is the results of the replay per endpoint
+type Report struct {
+	Endpoints map[string]*Endpoint `json:"endpoints"`
+}
+
+func newReport() *Report {
+	return &Report{Endpoints: map[string]*Endpoint{}}
+}
+
+// add adds the result, the entries are grouped by the recorded route pattern
+// and by the path when the pattern is not recorded.
+func (rp *Report) add(entry *middleware.HAREntry, result *Result) {
+	route := entry.RoutePattern
+	if route == "" {
+		route = entry.Request.URL
+		if u, err := url.Parse(entry.Request.URL); err == nil {
+			route = u.Path
+		}
+	}
+
+	name := entry.Request.Method + " " + route
+	ep, ok := rp.Endpoints[name]
+	if !ok {
+		ep = &Endpoint{Name: name}
+		rp.Endpoints[name] = ep
+	}
+
+	ep.Results = append(ep.Results, result)
+	switch {
+	case result.Skipped != "":
+		ep.Skipped++
+	case result.Passed():
+		ep.Passed++
+	default:
+		ep.Failed++
+	}
+}
+
+// Failed reports whether any replayed response doesn't match
+func (rp *Report) Failed() bool {
+	for _, ep := range rp.Endpoints {
+		if ep.Failed > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Write writes the summary per endpoint and the diffs of the failed requests
+//
+// EX:
+//
+//	POST /user          passed 12  failed 1  skipped 0
+//	  FAIL POST http://localhost/user (request 1792360276271062227)
+//	    status: want 201, got 400
+//	    body.error: want <missing>, got "email is required"
+func (rp *Report) Write(w io.Writer) error {
+	names := make([]string, 0, len(rp.Endpoints))
+	for name := range rp.Endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var passed, failed, skipped int
+	for _, name := range names {
+		ep := rp.Endpoints[name]
+		passed, failed, skipped = passed+ep.Passed, failed+ep.Failed, skipped+ep.Skipped
+
+		if _, err := fmt.Fprintf(w, "%-40s passed %d  failed %d  skipped %d\n", name, ep.Passed, ep.Failed, ep.Skipped); err != nil {
+			return err
+		}
+		for _, result := range ep.Results {
+			if result.Passed() || result.Skipped != "" {
+				continue
+			}
+
+			fmt.Fprintf(w, "  FAIL %s %s", result.Method, result.URL)
+			if result.RequestID != "" {
+				fmt.Fprintf(w, " (request %s)", result.RequestID)
+			}
+			fmt.Fprintln(w)
+			if result.Error != "" {
+				fmt.Fprintf(w, "    error: %s\n", result.Error)
+			}
+			for _, diff := range result.Diffs {
+				fmt.Fprintf(w, "    %s\n", diff)
+			}
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "\n%d passed, %d failed, %d skipped\n", passed, failed, skipped)
+	return err
+}
@@ -0,0 +1,213 @@
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/manigandand/adk/errors"
+)
+
+// MemoryStore is the in memory Store, meant to be used in the tests.
+// Filters & sorts are applied on the json field names of the resource.
+type MemoryStore[T any] struct {
+	mu    sync.RWMutex
+	ids   []string // keeps the insertion order
+	items map[string]*T
+}
+
+// NewMemoryStore returns the new in memory store
+func NewMemoryStore[T any]() *MemoryStore[T] {
+	return &MemoryStore[T]{
+		items: make(map[string]*T),
+	}
+}
+
+// List lists the resources which matches the filters
+func (s *MemoryStore[T]) List(ctx context.Context, q *Query) ([]*T, int64, *errors.AppError) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type row struct {
+		item   *T
+		fields map[string]interface{}
+	}
+	rows := make([]row, 0, len(s.ids))
+	for _, id := range s.ids {
+		item := s.items[id]
+		fields, err := jsonFields(item)
+		if err != nil {
+			return nil, 0, errors.InternalServerStd().AddDebug(err)
+		}
+		if !matchFilters(fields, q.Filters) {
+			continue
+		}
+		rows = append(rows, row{item: item, fields: fields})
+	}
+
+	if q.Sort != "" {
+		field := strings.TrimPrefix(q.Sort, "-")
+		desc := strings.HasPrefix(q.Sort, "-")
+		sort.SliceStable(rows, func(i, j int) bool {
+			if desc {
+				return less(rows[j].fields[field], rows[i].fields[field])
+			}
+			return less(rows[i].fields[field], rows[j].fields[field])
+		})
+	}
+
+	total := int64(len(rows))
+	size := max(total-q.Offset, 0)
+	if q.Limit > 0 {
+		size = min(size, q.Limit)
+	}
+	items := make([]*T, 0, size)
+	for i := q.Offset; i < total && (q.Limit <= 0 || i < q.Offset+q.Limit); i++ {
+		item, err := clone(rows[i].item)
+		if err != nil {
+			return nil, 0, errors.InternalServerStd().AddDebug(err)
+		}
+		items = append(items, item)
+	}
+
+	return items, total, nil
+}
+
+// Get returns the resource of the id
+func (s *MemoryStore[T]) Get(ctx context.Context, id string) (*T, *errors.AppError) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	item, ok := s.items[id]
+	if !ok {
+		return nil, errors.NotFound("resource not found")
+	}
+
+	cp, err := clone(item)
+	if err != nil {
+		return nil, errors.InternalServerStd().AddDebug(err)
+	}
+	return cp, nil
+}
+
+// Create stores the resource, a new uuid is set as id if the id is empty
+func (s *MemoryStore[T]) Create(ctx context.Context, item *T) *errors.AppError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := entity(item)
+	if e.GetID() == "" {
+		e.SetID(uuid.NewString())
+	}
+	id := e.GetID()
+	if _, ok := s.items[id]; ok {
+		return errors.Conflict("resource already exists")
+	}
+
+	cp, err := clone(item)
+	if err != nil {
+		return errors.InternalServerStd().AddDebug(err)
+	}
+	s.ids = append(s.ids, id)
+	s.items[id] = cp
+	return nil
+}
+
+// Update replaces the resource of the id
+func (s *MemoryStore[T]) Update(ctx context.Context, id string, item *T) *errors.AppError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.items[id]; !ok {
+		return errors.NotFound("resource not found")
+	}
+
+	cp, err := clone(item)
+	if err != nil {
+		return errors.InternalServerStd().AddDebug(err)
+	}
+	s.items[id] = cp
+	return nil
+}
+
+// Delete deletes the resource of the id
+func (s *MemoryStore[T]) Delete(ctx context.Context, id string) *errors.AppError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.items[id]; !ok {
+		return errors.NotFound("resource not found")
+	}
+
+	delete(s.items, id)
+	for i, v := range s.ids {
+		if v == id {
+			s.ids = append(s.ids[:i], s.ids[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// clone returns a deep copy by the json round trip, so the callers can't
+// mutate the nested maps, slices & pointers of the stored resource.
+// The fields which are not json encoded are not copied.
+func clone[T any](item *T) (*T, error) {
+	b, err := json.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+
+	cp := new(T)
+	if err := json.Unmarshal(b, cp); err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+func jsonFields(item interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func matchFilters(fields map[string]interface{}, filters map[string]string) bool {
+	for key, value := range filters {
+		v, ok := fields[key]
+		if !ok || fmt.Sprint(v) != value {
+			return false
+		}
+	}
+	return true
+}
+
+func less(a, b interface{}) bool {
+	switch av := a.(type) {
+	case float64:
+		if bv, ok := b.(float64); ok {
+			return av < bv
+		}
+	case string:
+		if bv, ok := b.(string); ok {
+			return av < bv
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			return !av && bv
+		}
+	}
+
+	// nil values are sorted first
+	return a == nil && b != nil
+}
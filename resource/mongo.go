@@ -0,0 +1,174 @@
+package resource
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/manigandand/adk/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoStore is the MongoDB Store of the resource. The id of the resource must
+// be stored in the _id field, filters & sorts are applied on the bson field names.
+//
+//	mongo.ErrNoDocuments returns errors.NotFound
+//	duplicate key errors returns errors.Conflict
+type MongoStore[T any] struct {
+	coll *mongo.Collection
+}
+
+// NewMongoStore returns the new mongo store of the collection
+func NewMongoStore[T any](coll *mongo.Collection) *MongoStore[T] {
+	return &MongoStore[T]{
+		coll: coll,
+	}
+}
+
+// List lists the resources which matches the filters
+func (s *MongoStore[T]) List(ctx context.Context, q *Query) ([]*T, int64, *errors.AppError) {
+	filter := bson.M{}
+	for key, value := range q.Filters {
+		filter[key] = filterValue(value)
+	}
+
+	total, err := s.coll.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, mongoError(err).Wrap(err, "count documents")
+	}
+
+	opts := options.Find().
+		SetSkip(q.Offset).
+		SetLimit(q.Limit)
+	if q.Sort != "" {
+		order := 1
+		if strings.HasPrefix(q.Sort, "-") {
+			order = -1
+		}
+		opts.SetSort(bson.D{{Key: strings.TrimPrefix(q.Sort, "-"), Value: order}})
+	}
+
+	cur, err := s.coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, mongoError(err).Wrap(err, "find documents")
+	}
+
+	var items []*T
+	if err := cur.All(ctx, &items); err != nil {
+		return nil, 0, mongoError(err).Wrap(err, "decode documents")
+	}
+	return items, total, nil
+}
+
+// Get returns the resource of the id
+func (s *MongoStore[T]) Get(ctx context.Context, id string) (*T, *errors.AppError) {
+	item := new(T)
+	if err := s.coll.FindOne(ctx, idFilter(id)).Decode(item); err != nil {
+		return nil, mongoError(err)
+	}
+	return item, nil
+}
+
+// Create inserts the resource, a new ObjectID hex is set as id if the id is empty
+func (s *MongoStore[T]) Create(ctx context.Context, item *T) *errors.AppError {
+	if e := entity(item); e.GetID() == "" {
+		e.SetID(primitive.NewObjectID().Hex())
+	}
+
+	if _, err := s.coll.InsertOne(ctx, item); err != nil {
+		return mongoError(err)
+	}
+	return nil
+}
+
+// Update replaces the resource of the id, the stored _id is not changed
+func (s *MongoStore[T]) Update(ctx context.Context, id string, item *T) *errors.AppError {
+	doc, err := replacement(item)
+	if err != nil {
+		return errors.InternalServerStd().AddDebug(err)
+	}
+
+	res, err := s.coll.ReplaceOne(ctx, idFilter(id), doc)
+	if err != nil {
+		return mongoError(err)
+	}
+	if res.MatchedCount == 0 {
+		return mongoError(mongo.ErrNoDocuments)
+	}
+	return nil
+}
+
+// Delete deletes the resource of the id
+func (s *MongoStore[T]) Delete(ctx context.Context, id string) *errors.AppError {
+	res, err := s.coll.DeleteOne(ctx, idFilter(id))
+	if err != nil {
+		return mongoError(err)
+	}
+	if res.DeletedCount == 0 {
+		return mongoError(mongo.ErrNoDocuments)
+	}
+	return nil
+}
+
+// idFilter matches the _id stored either as ObjectID or as string
+func idFilter(id string) bson.M {
+	if oid, err := primitive.ObjectIDFromHex(id); err == nil {
+		return bson.M{"_id": bson.M{"$in": bson.A{oid, id}}}
+	}
+	return bson.M{"_id": id}
+}
+
+// replacement returns the document of the resource without the _id field, so
+// the stored _id is kept as it is, either ObjectID or string.
+func replacement(item interface{}) (bson.D, error) {
+	b, err := bson.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc bson.D
+	if err := bson.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+
+	fields := doc[:0]
+	for _, e := range doc {
+		if e.Key != "_id" {
+			fields = append(fields, e)
+		}
+	}
+	return fields, nil
+}
+
+// filterValue matches the query param value either as string or as the
+// parsed bool/number value
+func filterValue(value string) interface{} {
+	var parsed interface{}
+	if b, err := strconv.ParseBool(value); err == nil {
+		parsed = b
+	} else if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		parsed = n
+	} else if f, err := strconv.ParseFloat(value, 64); err == nil {
+		parsed = f
+	}
+
+	if parsed == nil {
+		return value
+	}
+	return bson.M{"$in": bson.A{value, parsed}}
+}
+
+// mongoError converts the mongo driver errors into the app error
+func mongoError(err error) *errors.AppError {
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return errors.NotFound("resource not found").AddDebug(err)
+	case mongo.IsDuplicateKeyError(err):
+		return errors.Conflict("resource already exists").AddDebug(err)
+	}
+
+	return errors.InternalServerStd().AddDebug(err)
+}
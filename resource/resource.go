@@ -0,0 +1,277 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/manigandand/adk/api"
+	"github.com/manigandand/adk/errors"
+	"github.com/manigandand/adk/respond"
+)
+
+// Entity must be implemented by the pointer of the resource type, it gives the
+// generic handlers and stores access to the resource id.
+//
+// EX:
+//
+//	type User struct {
+//		ID    string `json:"id" bson:"_id"`
+//		Email string `json:"email" bson:"email"`
+//	}
+//
+//	func (u *User) GetID() string   { return u.ID }
+//	func (u *User) SetID(id string) { u.ID = id }
+type Entity interface {
+	GetID() string
+	SetID(id string)
+}
+
+// Store is the persistence layer of the resource.
+// Stores should return errors.NotFound if the resource doesn't exist and
+// errors.Conflict if the resource already exists.
+type Store[T any] interface {
+	List(ctx context.Context, q *Query) ([]*T, int64, *errors.AppError)
+	Get(ctx context.Context, id string) (*T, *errors.AppError)
+	Create(ctx context.Context, item *T) *errors.AppError
+	Update(ctx context.Context, id string, item *T) *errors.AppError
+	Delete(ctx context.Context, id string) *errors.AppError
+}
+
+// Query holds the pagination, sorting and filter params of the list request
+type Query struct {
+	Limit  int64
+	Offset int64
+	// Sort is the field name to sort the list, prefixed with - for the
+	// descending order. Ex: -created_at
+	Sort string
+	// Filters are the field name & value to match exactly. Ex: ?email=x@y.com
+	Filters map[string]string
+}
+
+// Pagination holds the pagination info of the list response
+type Pagination struct {
+	Total  int64 `json:"total"`
+	Limit  int64 `json:"limit"`
+	Offset int64 `json:"offset"`
+}
+
+// ListResponse is the response of the list resource endpoint
+type ListResponse[T any] struct {
+	Data []*T       `json:"data"`
+	Meta Pagination `json:"meta"`
+}
+
+// Default pagination limits, exported so that it can be changed by developers
+var (
+	DefaultLimit int64 = 20
+	MaxLimit     int64 = 100
+)
+
+// Resource mounts the list, get, create, update & delete handlers of the
+// resource type T over the Store.
+//
+// EX:
+//
+//	users := resource.New[User]("user", resource.NewMemoryStore[User]())
+//	users.Filters = []string{"email"}
+//	r.Mount("/users", users.Routes())
+type Resource[T any] struct {
+	// Name of the resource used in the error messages. Ex: user not found
+	Name  string
+	Store Store[T]
+	// Filters are the query params allowed to filter the list
+	Filters []string
+	// Sorts are the fields allowed to sort the list
+	Sorts []string
+	// DefaultLimit & MaxLimit of the list pagination
+	DefaultLimit int64
+	MaxLimit     int64
+}
+
+// New returns the new resource of the type T. New panics if *T doesn't
+// implement the Entity interface.
+func New[T any](name string, store Store[T]) *Resource[T] {
+	if _, ok := interface{}(new(T)).(Entity); !ok {
+		panic(fmt.Sprintf("resource: *%T must implement resource.Entity", *new(T)))
+	}
+
+	return &Resource[T]{
+		Name:         name,
+		Store:        store,
+		DefaultLimit: DefaultLimit,
+		MaxLimit:     MaxLimit,
+	}
+}
+
+// Routes returns the router with all the resource routes
+//
+//	GET    /      list the resources
+//	POST   /      create a resource
+//	GET    /{id}  get a resource
+//	PATCH  /{id}  partially update a resource, only the fields in the payload are updated
+//	DELETE /{id}  delete a resource
+func (res *Resource[T]) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Method(http.MethodGet, "/", api.Handler(res.List))
+	r.Method(http.MethodPost, "/", api.Handler(res.Create))
+	r.Method(http.MethodGet, "/{id}", api.Handler(res.Get))
+	r.Method(http.MethodPatch, "/{id}", api.Handler(res.Update))
+	r.Method(http.MethodDelete, "/{id}", api.Handler(res.Delete))
+
+	return r
+}
+
+// List lists the resources with the pagination, sorting & filters
+func (res *Resource[T]) List(w http.ResponseWriter, r *http.Request) *errors.AppError {
+	q, err := res.parseQuery(r)
+	if err != nil {
+		return err
+	}
+
+	items, total, err := res.Store.List(r.Context(), q)
+	if err != nil {
+		return res.storeErr(err)
+	}
+	if items == nil {
+		items = []*T{}
+	}
+
+	return respond.OK(w, &ListResponse[T]{
+		Data: items,
+		Meta: Pagination{
+			Total:  total,
+			Limit:  q.Limit,
+			Offset: q.Offset,
+		},
+	})
+}
+
+// Get returns the resource of the id
+func (res *Resource[T]) Get(w http.ResponseWriter, r *http.Request) *errors.AppError {
+	item, err := res.Store.Get(r.Context(), api.PathParam(r, "id"))
+	if err != nil {
+		return res.storeErr(err)
+	}
+
+	return respond.OK(w, item)
+}
+
+// Create creates a new resource, the id sent in the payload is ignored
+func (res *Resource[T]) Create(w http.ResponseWriter, r *http.Request) *errors.AppError {
+	item := new(T)
+	if err := api.Decode(r, item); err != nil {
+		return err
+	}
+	entity(item).SetID("")
+
+	if err := res.Store.Create(r.Context(), item); err != nil {
+		return res.storeErr(err)
+	}
+
+	return respond.Created(w, item)
+}
+
+// Update partially updates the resource, only the fields present in the
+// payload are updated. The id can't be updated.
+func (res *Resource[T]) Update(w http.ResponseWriter, r *http.Request) *errors.AppError {
+	ctx := r.Context()
+	id := api.PathParam(r, "id")
+
+	item, err := res.Store.Get(ctx, id)
+	if err != nil {
+		return res.storeErr(err)
+	}
+
+	// decoding on the existing resource overrides only the fields in the payload
+	if err := api.Decode(r, item); err != nil {
+		return err
+	}
+	entity(item).SetID(id)
+
+	if err := res.Store.Update(ctx, id, item); err != nil {
+		return res.storeErr(err)
+	}
+
+	return respond.OK(w, item)
+}
+
+// Delete deletes the resource of the id
+func (res *Resource[T]) Delete(w http.ResponseWriter, r *http.Request) *errors.AppError {
+	if err := res.Store.Delete(r.Context(), api.PathParam(r, "id")); err != nil {
+		return res.storeErr(err)
+	}
+
+	return respond.NoContent(w, nil)
+}
+
+// parseQuery parses the pagination, sort & filter query params
+func (res *Resource[T]) parseQuery(r *http.Request) (*Query, *errors.AppError) {
+	params := r.URL.Query()
+	q := &Query{
+		Limit:   res.DefaultLimit,
+		Filters: make(map[string]string),
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil || n <= 0 {
+			return nil, errors.InvalidKey(limit, "limit")
+		}
+		q.Limit = n
+	}
+	if res.MaxLimit > 0 && q.Limit > res.MaxLimit {
+		q.Limit = res.MaxLimit
+	}
+
+	if offset := params.Get("offset"); offset != "" {
+		n, err := strconv.ParseInt(offset, 10, 64)
+		if err != nil || n < 0 {
+			return nil, errors.InvalidKey(offset, "offset")
+		}
+		q.Offset = n
+	}
+
+	if sort := params.Get("sort"); sort != "" {
+		if !contains(res.Sorts, strings.TrimPrefix(sort, "-")) {
+			return nil, errors.InvalidKey(sort, "sort")
+		}
+		q.Sort = sort
+	}
+
+	for _, field := range res.Filters {
+		if value := params.Get(field); value != "" {
+			q.Filters[field] = value
+		}
+	}
+
+	return q, nil
+}
+
+// storeErr adds the resource name in the not found & conflict errors
+func (res *Resource[T]) storeErr(err *errors.AppError) *errors.AppError {
+	switch err.GetStatus() {
+	case http.StatusNotFound:
+		err.UpdateMsg(res.Name + " not found")
+	case http.StatusConflict:
+		err.UpdateMsg(res.Name + " already exists")
+	}
+	return err
+}
+
+func entity(item interface{}) Entity {
+	return item.(Entity)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
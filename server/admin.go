@@ -0,0 +1,296 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/manigandand/adk/api"
+	"github.com/manigandand/adk/background"
+	"github.com/manigandand/adk/config"
+	"github.com/manigandand/adk/errors"
+	"github.com/manigandand/adk/middleware"
+	"github.com/manigandand/adk/respond"
+	"github.com/manigandand/adk/versioning"
+	log "github.com/sirupsen/logrus"
+)
+
+// ReadyCheckTimeout limits the readiness checks, exported so that it can be
+// changed by developers
+var ReadyCheckTimeout = 5 * time.Second
+
+// Admin is the admin listener, which keeps the health, metrics & debug
+// endpoints off the public port. Its lifecycle is tied to the Server, the
+// readiness fails as soon as the graceful shutdown starts, so the load
+// balancers stop routing, and the admin listener is closed last.
+//
+//	GET       /health           liveness, the service info
+//	GET       /ready            readiness checks, 503 with the failed checks
+//	GET       /metrics          Prometheus text format metrics
+//	GET       /debug/pprof/*    pprof profiles
+//	GET       /debug/vars       expvar
+//	GET       /buildinfo        build & vcs info of the binary
+//	GET, PUT  /loglevel         log level, Ex: {"level": "debug"}
+//	GET       /routes           routes of the public router
+//	GET       /config           redacted dump of the Config
+//
+// EX:
+//
+//	admin := server.NewAdmin("127.0.0.1:9090")
+//	admin.Routes = r
+//	admin.AddCheck("mongo", func(ctx context.Context) error { return client.Ping(ctx, nil) })
+//
+//	srv := server.New(":3000", r)
+//	srv.Admin = admin
+type Admin struct {
+	Addr string
+	// Router is the admin router, Ex: to add the custom admin endpoints
+	Router chi.Router
+	// Routes is the public router listed on /routes
+	Routes chi.Routes
+	// Config is the config struct dumped on /config, the secrets are redacted
+	Config interface{}
+
+	mu         sync.RWMutex
+	checks     []readyCheck
+	draining   atomic.Bool
+	started    time.Time
+	background *background.Group
+	http       *http.Server
+}
+
+type readyCheck struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// NewAdmin returns the admin listener with the admin endpoints
+func NewAdmin(addr string) *Admin {
+	a := &Admin{
+		Addr:       addr,
+		Router:     chi.NewRouter(),
+		started:    time.Now(),
+		background: background.DefaultGroup,
+	}
+
+	a.Router.Use(middleware.Recoverer)
+	a.Router.Get("/health", api.HealthHandeler)
+	a.Router.Method(http.MethodGet, "/ready", api.Handler(a.ready))
+	a.Router.Get("/metrics", a.metrics)
+	a.Router.Mount("/debug", chimiddleware.Profiler())
+	a.Router.Method(http.MethodGet, "/buildinfo", api.Handler(buildInfo))
+	a.Router.Method(http.MethodGet, "/loglevel", api.Handler(getLogLevel))
+	a.Router.Method(http.MethodPut, "/loglevel", api.Handler(setLogLevel))
+	a.Router.Method(http.MethodGet, "/routes", api.Handler(a.routes))
+	a.Router.Method(http.MethodGet, "/config", api.Handler(a.config))
+	return a
+}
+
+// AddCheck adds the readiness check, Ex: ping the database
+func (a *Admin) AddCheck(name string, fn func(ctx context.Context) error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.checks = append(a.checks, readyCheck{name: name, fn: fn})
+}
+
+// Drain fails the readiness, called when the server starts shutting down
+func (a *Admin) Drain() {
+	a.draining.Store(true)
+}
+
+// start starts the admin listener
+func (a *Admin) start() error {
+	l, err := Listen(a.Addr)
+	if err != nil {
+		return err
+	}
+
+	a.http = &http.Server{
+		Handler:           a.Router,
+		ReadHeaderTimeout: DefaultReadHeaderTimeout,
+	}
+	go func() {
+		if err := a.http.Serve(l); err != nil && err != http.ErrServerClosed {
+			log.Error("server: admin listener: ", err)
+		}
+	}()
+	log.Infof("server: admin listening on %s", l.Addr())
+	return nil
+}
+
+// shutdown closes the admin listener
+func (a *Admin) shutdown(ctx context.Context) error {
+	if a.http == nil {
+		return nil
+	}
+	return a.http.Shutdown(ctx)
+}
+
+// close closes the admin listener immediately, when the server fails
+func (a *Admin) close() {
+	if a.http != nil {
+		a.http.Close()
+	}
+}
+
+func (a *Admin) ready(w http.ResponseWriter, r *http.Request) *errors.AppError {
+	if a.draining.Load() {
+		return errors.NewAppError(http.StatusServiceUnavailable, "shutting down").WithNoLog()
+	}
+
+	a.mu.RLock()
+	checks := append([]readyCheck(nil), a.checks...)
+	a.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(r.Context(), ReadyCheckTimeout)
+	defer cancel()
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		failed = map[string]string{}
+	)
+	for _, check := range checks {
+		wg.Add(1)
+		go func(check readyCheck) {
+			defer wg.Done()
+			if err := check.fn(ctx); err != nil {
+				mu.Lock()
+				failed[check.name] = err.Error()
+				mu.Unlock()
+			}
+		}(check)
+	}
+	wg.Wait()
+
+	if len(failed) > 0 {
+		return errors.NewAppError(http.StatusServiceUnavailable, "not ready").
+			AddConflictData(map[string]interface{}{"failed": failed}).WithNoLog()
+	}
+	return respond.OK(w, map[string]interface{}{"status": "ready", "checks": len(checks)})
+}
+
+// metrics writes the runtime metrics in the Prometheus text format
+func (a *Admin) metrics(w http.ResponseWriter, r *http.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	b := &strings.Builder{}
+	metric := func(name, typ, help string, value interface{}) {
+		fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, typ, name, value)
+	}
+
+	metric("go_goroutines", "gauge", "Number of goroutines that currently exist.", runtime.NumGoroutine())
+	metric("go_memstats_heap_alloc_bytes", "gauge", "Number of heap bytes allocated and still in use.", mem.HeapAlloc)
+	metric("go_memstats_sys_bytes", "gauge", "Number of bytes obtained from system.", mem.Sys)
+	metric("go_gc_cycles_total", "counter", "Number of completed GC cycles.", mem.NumGC)
+	metric("process_uptime_seconds", "gauge", "Seconds since the process started.", time.Since(a.started).Seconds())
+	metric("adk_background_tasks_in_flight", "gauge", "Number of the running background tasks.", a.background.InFlight())
+
+	calls := versioning.DeprecatedCalls()
+	if len(calls) > 0 {
+		keys := make([]string, 0, len(calls))
+		for key := range calls {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		fmt.Fprint(b, "# HELP adk_deprecated_calls_total Number of the calls of the deprecated versions & routes.\n")
+		fmt.Fprint(b, "# TYPE adk_deprecated_calls_total counter\n")
+		for _, key := range keys {
+			// <version> <method> <route pattern>
+			version, route, _ := strings.Cut(key, " ")
+			fmt.Fprintf(b, "adk_deprecated_calls_total{version=%q,route=%q} %d\n", version, route, calls[key])
+		}
+	}
+
+	_, _ = w.Write([]byte(b.String()))
+}
+
+func buildInfo(w http.ResponseWriter, r *http.Request) *errors.AppError {
+	info := map[string]interface{}{
+		"service":    api.GetServiceInfo(),
+		"go_version": runtime.Version(),
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info["path"] = bi.Path
+		info["main"] = bi.Main
+		settings := map[string]string{}
+		for _, s := range bi.Settings {
+			if strings.HasPrefix(s.Key, "vcs") || s.Key == "GOOS" || s.Key == "GOARCH" || s.Key == "CGO_ENABLED" {
+				settings[s.Key] = s.Value
+			}
+		}
+		info["settings"] = settings
+	}
+	return respond.OK(w, info)
+}
+
+type logLevelReq struct {
+	Level string `json:"level" normalize:"trim,lower"`
+}
+
+// Validate implements the custom validator interface
+func (req *logLevelReq) Validate() *errors.AppError {
+	if req.Level == "" {
+		return errors.KeyRequired("level")
+	}
+	if _, err := log.ParseLevel(req.Level); err != nil {
+		return errors.InvalidKey(req.Level, "level")
+	}
+	return nil
+}
+
+func getLogLevel(w http.ResponseWriter, r *http.Request) *errors.AppError {
+	return respond.OK(w, map[string]interface{}{"level": log.GetLevel().String()})
+}
+
+func setLogLevel(w http.ResponseWriter, r *http.Request) *errors.AppError {
+	var req logLevelReq
+	if err := api.Decode(r, &req); err != nil {
+		return err
+	}
+
+	level, _ := log.ParseLevel(req.Level)
+	log.Warnf("server: log level changed from %s to %s", log.GetLevel(), level)
+	log.SetLevel(level)
+	return respond.OK(w, map[string]interface{}{"level": level.String()})
+}
+
+func (a *Admin) routes(w http.ResponseWriter, r *http.Request) *errors.AppError {
+	type route struct {
+		Method string `json:"method"`
+		Route  string `json:"route"`
+	}
+
+	routes := []route{}
+	if a.Routes != nil {
+		err := chi.Walk(a.Routes, func(method, pattern string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+			routes = append(routes, route{Method: method, Route: pattern})
+			return nil
+		})
+		if err != nil {
+			return errors.InternalServerStd().AddDebug(err)
+		}
+	}
+	return respond.OK(w, routes)
+}
+
+func (a *Admin) config(w http.ResponseWriter, r *http.Request) *errors.AppError {
+	if a.Config == nil {
+		return errors.NotFound("config is not set").WithNoLog()
+	}
+	return respond.OK(w, config.Redact(a.Config))
+}
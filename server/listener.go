@@ -0,0 +1,132 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// UnixSocketMode is the file mode of the unix domain sockets, exported so that
+// it can be changed by developers
+var UnixSocketMode os.FileMode = 0o660
+
+// systemd socket activation, the passed fds start from 3
+// https://www.freedesktop.org/software/systemd/man/sd_listen_fds.html
+const listenFdsStart = 3
+
+// Listen returns the listener of the address, configured without changing the
+// handlers. The addresses are:
+//
+//	:3000, 127.0.0.1:3000     tcp
+//	unix:/run/api/api.sock     unix domain socket, the stale socket is removed
+//	systemd                    the first systemd socket activated listener
+//	systemd:<name>             the listener of the FileDescriptorName=<name>
+func Listen(addr string) (net.Listener, error) {
+	switch {
+	case strings.HasPrefix(addr, "unix:"):
+		return listenUnix(strings.TrimPrefix(strings.TrimPrefix(addr, "unix:"), "//"))
+	case addr == "systemd":
+		return listenSystemd("")
+	case strings.HasPrefix(addr, "systemd:"):
+		return listenSystemd(strings.TrimPrefix(addr, "systemd:"))
+	}
+	return net.Listen("tcp", addr)
+}
+
+func listenUnix(path string) (net.Listener, error) {
+	// the socket of the previous run, which is not cleaned up
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, UnixSocketMode); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// systemdListener is the socket activated listener and its FileDescriptorName
+type systemdListener struct {
+	name string
+	l    net.Listener
+}
+
+// systemdListeners are the socket activated listeners in the fd order, passed
+// once per process.
+var (
+	systemdMu        sync.Mutex
+	systemdListeners []*systemdListener
+	systemdLoaded    bool
+)
+
+// listenSystemd returns the first unused listener of the name, any name when
+// the name is empty.
+func listenSystemd(name string) (net.Listener, error) {
+	systemdMu.Lock()
+	defer systemdMu.Unlock()
+
+	if !systemdLoaded {
+		listeners, err := systemdFiles()
+		if err != nil {
+			return nil, err
+		}
+		systemdListeners, systemdLoaded = listeners, true
+	}
+
+	for i, sl := range systemdListeners {
+		if sl != nil && (name == "" || sl.name == name) {
+			systemdListeners[i] = nil
+			return sl.l, nil
+		}
+	}
+	return nil, fmt.Errorf("server: no systemd socket %q, LISTEN_FDS=%q LISTEN_FDNAMES=%q",
+		name, os.Getenv("LISTEN_FDS"), os.Getenv("LISTEN_FDNAMES"))
+}
+
+// systemdFiles returns the listeners passed by systemd, the env vars are
+// unset so the child processes don't inherit them.
+func systemdFiles() ([]*systemdListener, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	listeners := make([]*systemdListener, 0, n)
+	for i := 0; i < n; i++ {
+		fd := listenFdsStart + i
+		name := "LISTEN_FD_" + strconv.Itoa(fd)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		f := os.NewFile(uintptr(fd), name)
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("server: systemd socket %s: %w", name, err)
+		}
+		listeners = append(listeners, &systemdListener{name: name, l: l})
+	}
+	return listeners, nil
+}
@@ -0,0 +1,273 @@
+// Package server runs the http handlers on the configurable listeners, TLS
+// with the certificate hot-reload, HTTP/2 cleartext (h2c), unix domain sockets
+// & systemd socket activation, and shuts down gracefully, waiting for the
+// in-flight requests & the background tasks.
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/manigandand/adk/background"
+	"github.com/manigandand/adk/config"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// Server defaults, exported so that it can be changed by developers
+var (
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultIdleTimeout       = 120 * time.Second
+	DefaultShutdownTimeout   = 30 * time.Second
+)
+
+// Server is the http server. The listener & the protocols are configured by
+// the fields, the handlers are not changed.
+//
+// EX:
+//
+//	srv := server.New(":8443", r)
+//	srv.CertFile, srv.KeyFile = "/etc/tls/tls.crt", "/etc/tls/tls.key"
+//	log.Fatal(srv.Run())
+//
+//	srv := server.New("unix:/run/api/api.sock", r)
+//	srv.H2C = true // mesh sidecars speaking HTTP/2 prior knowledge
+type Server struct {
+	// Addr is the listen address, see Listen. Ex: :3000, unix:/run/api.sock, systemd
+	Addr    string
+	Handler http.Handler
+
+	// CertFile & KeyFile enable TLS, the files are watched & reloaded
+	CertFile string
+	KeyFile  string
+	// TLSConfig is the base TLS config, Ex: MinVersion, ClientCAs
+	TLSConfig *tls.Config
+	// H2C serves HTTP/2 without TLS, with the prior knowledge or the upgrade.
+	// The h2c connections get the GOAWAY on the shutdown, but they're
+	// hijacked, so the shutdown doesn't wait for their in-flight streams.
+	H2C bool
+
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// ShutdownTimeout limits the graceful shutdown, the requests & the
+	// background tasks which outlive it are cancelled.
+	ShutdownTimeout time.Duration
+	// DrainDelay is waited after failing the admin readiness, before the
+	// listener is closed, so the load balancers stop sending the requests.
+	// It's not counted in the ShutdownTimeout.
+	DrainDelay time.Duration
+	// Background is the task group waited on shutdown, defaults to background.DefaultGroup
+	Background *background.Group
+	// Admin is the admin listener, started & shut down with the server
+	Admin *Admin
+
+	onShutdown []func(ctx context.Context) error
+	listener   net.Listener
+	http       *http.Server
+}
+
+// New returns the server of the handler with the defaults
+func New(addr string, h http.Handler) *Server {
+	return &Server{
+		Addr:              addr,
+		Handler:           h,
+		ReadHeaderTimeout: DefaultReadHeaderTimeout,
+		IdleTimeout:       DefaultIdleTimeout,
+		ShutdownTimeout:   DefaultShutdownTimeout,
+		Background:        background.DefaultGroup,
+	}
+}
+
+// FromConfig returns the server of the service config, with the admin
+// listener if the AdminAddr is set. cfg is dumped, redacted, on the admin
+// /config endpoint.
+//
+// EX:
+//
+//	var cfg Config // embeds config.Service
+//	if err := config.Load(&cfg); err != nil {
+//		log.Fatal(err)
+//	}
+//	cfg.Init()
+//	log.Fatal(server.FromConfig(cfg.Service, r, &cfg).Run())
+func FromConfig(svc config.Service, h http.Handler, cfg interface{}) *Server {
+	s := New(svc.Addr, h)
+	s.CertFile, s.KeyFile = svc.CertFile, svc.KeyFile
+	s.H2C = svc.H2C
+	if svc.ShutdownTimeout > 0 {
+		s.ShutdownTimeout = svc.ShutdownTimeout
+	}
+	s.DrainDelay = svc.DrainDelay
+
+	if svc.AdminAddr != "" {
+		s.Admin = NewAdmin(svc.AdminAddr)
+		s.Admin.Config = cfg
+		if routes, ok := h.(chi.Routes); ok {
+			s.Admin.Routes = routes
+		}
+	}
+	return s
+}
+
+// OnShutdown registers fn, called on the graceful shutdown after the
+// requests & the background tasks are finished. Ex: close the db clients
+func (s *Server) OnShutdown(fn func(ctx context.Context) error) {
+	s.onShutdown = append(s.onShutdown, fn)
+}
+
+// Run serves until SIGINT or SIGTERM, then shuts down gracefully
+func (s *Server) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return s.Serve(ctx)
+}
+
+// Serve serves until the ctx is done, then shuts down gracefully. Returns nil
+// when the server is shut down cleanly.
+func (s *Server) Serve(ctx context.Context) error {
+	if err := s.listen(ctx); err != nil {
+		return err
+	}
+	if s.Admin != nil {
+		if s.Background != nil {
+			s.Admin.background = s.Background
+		}
+		if err := s.Admin.start(); err != nil {
+			s.listener.Close()
+			return err
+		}
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		var err error
+		if s.http.TLSConfig != nil {
+			err = s.http.ServeTLS(s.listener, "", "")
+		} else {
+			err = s.http.Serve(s.listener)
+		}
+		serveErr <- err
+	}()
+	log.Infof("server: listening on %s", s.listener.Addr())
+
+	select {
+	case err := <-serveErr:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		if s.Admin != nil {
+			s.Admin.close()
+		}
+		return err
+	case <-ctx.Done():
+	}
+	return s.Shutdown()
+}
+
+// listen opens the listener and prepares the http server
+func (s *Server) listen(ctx context.Context) error {
+	l, err := Listen(s.Addr)
+	if err != nil {
+		return err
+	}
+
+	s.listener = l
+	s.http = &http.Server{
+		Handler:           s.Handler,
+		ReadHeaderTimeout: s.ReadHeaderTimeout,
+		ReadTimeout:       s.ReadTimeout,
+		WriteTimeout:      s.WriteTimeout,
+		IdleTimeout:       s.IdleTimeout,
+	}
+
+	if s.H2C {
+		// the h2c connections are hijacked from the http server, configuring
+		// the http2 server registers it on the shutdown, so the GOAWAY is sent
+		h2s := &http2.Server{IdleTimeout: s.IdleTimeout}
+		if err := http2.ConfigureServer(s.http, h2s); err != nil {
+			l.Close()
+			return err
+		}
+		s.http.TLSConfig = nil // set by ConfigureServer, TLS is configured below
+		s.http.Handler = h2c.NewHandler(s.Handler, h2s)
+	}
+
+	if s.CertFile == "" && s.TLSConfig == nil {
+		return nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if s.TLSConfig != nil {
+		tlsConfig = s.TLSConfig.Clone()
+	}
+	if s.CertFile != "" {
+		certs, err := NewCertReloader(s.CertFile, s.KeyFile)
+		if err != nil {
+			l.Close()
+			return err
+		}
+		go certs.Watch(ctx)
+		tlsConfig.GetCertificate = certs.GetCertificate
+	}
+	s.http.TLSConfig = tlsConfig
+	return nil
+}
+
+// Shutdown fails the admin readiness and waits for the DrainDelay, then stops
+// accepting the connections, waits for the in-flight requests, then the
+// background tasks and runs the OnShutdown funcs, all within the
+// ShutdownTimeout. The admin listener is closed last.
+func (s *Server) Shutdown() error {
+	if s.Admin != nil {
+		s.Admin.Drain()
+	}
+	if s.DrainDelay > 0 {
+		log.Infof("server: draining, waiting %v before shutting down", s.DrainDelay)
+		time.Sleep(s.DrainDelay)
+	}
+
+	timeout := s.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	log.Infof("server: shutting down, waiting up to %v", timeout)
+
+	var errs []error
+	if s.http != nil {
+		if err := s.http.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+			s.http.Close()
+		}
+	}
+	if s.Background != nil {
+		if err := s.Background.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, fn := range s.onShutdown {
+		if err := fn(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if s.Admin != nil {
+		if err := s.Admin.shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// CertReloadInterval is the polling interval of the certificate files,
+// exported so that it can be changed by developers
+var CertReloadInterval = 30 * time.Second
+
+// CertReloader serves the certificate of the files and reloads it when the
+// files change, so the rotated certificates are served without the restart.
+// The files are polled, which works with the kubernetes secret volumes that
+// swap the symlinks. The failed reloads are logged and the previous
+// certificate is served.
+//
+// EX:
+//
+//	certs, err := server.NewCertReloader("/etc/tls/tls.crt", "/etc/tls/tls.key")
+//	go certs.Watch(ctx)
+//	srv.TLSConfig = &tls.Config{GetCertificate: certs.GetCertificate}
+type CertReloader struct {
+	CertFile string
+	KeyFile  string
+	Interval time.Duration
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	version string
+}
+
+// NewCertReloader loads the certificate of the files
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	c := &CertReloader{
+		CertFile: certFile,
+		KeyFile:  keyFile,
+		Interval: CertReloadInterval,
+	}
+	if _, err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (c *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.cert, nil
+}
+
+// Reload loads the certificate if the files are changed, returns true when
+// the new certificate is loaded.
+func (c *CertReloader) Reload() (bool, error) {
+	version, err := c.fileVersion()
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.RLock()
+	unchanged := version == c.version
+	c.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	c.cert, c.version = &cert, version
+	c.mu.Unlock()
+	return true, nil
+}
+
+// fileVersion identifies the contents of the files by the size & the modtime
+func (c *CertReloader) fileVersion() (string, error) {
+	var version string
+	for _, file := range []string{c.CertFile, c.KeyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", err
+		}
+		version += fmt.Sprintf("%s:%d:%d;", file, info.ModTime().UnixNano(), info.Size())
+	}
+	return version, nil
+}
+
+// Watch polls the files every Interval until the ctx is done
+func (c *CertReloader) Watch(ctx context.Context) {
+	interval := c.Interval
+	if interval <= 0 {
+		interval = CertReloadInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			reloaded, err := c.Reload()
+			if err != nil {
+				log.Errorf("server: reload certificate %s: %v, serving the previous certificate", c.CertFile, err)
+				continue
+			}
+			if reloaded {
+				log.Infof("server: reloaded certificate %s", c.CertFile)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
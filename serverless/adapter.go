@@ -0,0 +1,275 @@
+package serverless
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/manigandand/adk/errors"
+	"github.com/manigandand/adk/middleware"
+)
+
+type contextKey string
+
+const (
+	eventV1Key contextKey = "serverless.event.v1"
+	eventV2Key contextKey = "serverless.event.v2"
+)
+
+// Adapter runs the http handler on the API Gateway v1/v2 proxy events.
+// The handler is usually the chi router with the api.Handler's & middlewares.
+// The request id of the event is set as the request id header, so it's logged
+// by middleware.Logger.
+//
+// EX:
+//
+//	r := chi.NewRouter()
+//	r.Use(middleware.Logger)
+//	r.Use(middleware.Recoverer)
+//	r.Method(http.MethodPost, "/user", api.Handler(CreateUserHandler))
+//
+//	adapter := serverless.New(r)
+//	// Invoke matches the aws-lambda-go lambda.Handler interface
+//	lambda.StartHandler(adapter)
+type Adapter struct {
+	Handler http.Handler
+}
+
+// New returns the new adapter of the handler
+func New(h http.Handler) *Adapter {
+	return &Adapter{
+		Handler: h,
+	}
+}
+
+// Invoke runs the handler on the v1 or v2 proxy event JSON payload and returns
+// the response event JSON. The version is detected from the event.
+func (a *Adapter) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	var probe struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(payload, &probe); err != nil {
+		return nil, errors.Wrap(err, "serverless: decode event")
+	}
+
+	if probe.Version == "2.0" {
+		var event APIGatewayV2HTTPRequest
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, errors.Wrap(err, "serverless: decode v2 event")
+		}
+		res, err := a.ProxyV2(ctx, &event)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(res)
+	}
+
+	var event APIGatewayProxyRequest
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, errors.Wrap(err, "serverless: decode v1 event")
+	}
+	res, err := a.ProxyV1(ctx, &event)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(res)
+}
+
+// InvokeFile runs the handler on the event JSON file. Used to run the
+// handlers locally with the fixture event files.
+func (a *Adapter) InvokeFile(ctx context.Context, path string) ([]byte, error) {
+	payload, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "serverless: read event file")
+	}
+
+	return a.Invoke(ctx, payload)
+}
+
+// ProxyV1 runs the handler on the v1 (REST API) proxy event
+func (a *Adapter) ProxyV1(ctx context.Context, event *APIGatewayProxyRequest) (*APIGatewayProxyResponse, error) {
+	body, err := eventBody(event.Body, event.IsBase64Encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	for key, values := range event.MultiValueQueryStringParameters {
+		query[key] = values
+	}
+	for key, value := range event.QueryStringParameters {
+		if _, ok := query[key]; !ok {
+			query.Set(key, value)
+		}
+	}
+
+	ctx = context.WithValue(ctx, eventV1Key, event)
+	u := &url.URL{
+		Path:     event.Path,
+		RawQuery: query.Encode(),
+	}
+	r, err := newRequest(ctx, event.HTTPMethod, u, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range event.MultiValueHeaders {
+		for _, value := range values {
+			r.Header.Add(key, value)
+		}
+	}
+	for key, value := range event.Headers {
+		if r.Header.Get(key) == "" {
+			r.Header.Set(key, value)
+		}
+	}
+	setRequestInfo(r, event.RequestContext.RequestID, event.RequestContext.Identity.SourceIP)
+
+	res, body := a.serve(r)
+	resBody, isBase64 := responseBody(res.Header, body)
+	return &APIGatewayProxyResponse{
+		StatusCode:        res.StatusCode,
+		MultiValueHeaders: res.Header,
+		Body:              resBody,
+		IsBase64Encoded:   isBase64,
+	}, nil
+}
+
+// ProxyV2 runs the handler on the v2 (HTTP API) proxy event
+func (a *Adapter) ProxyV2(ctx context.Context, event *APIGatewayV2HTTPRequest) (*APIGatewayV2HTTPResponse, error) {
+	body, err := eventBody(event.Body, event.IsBase64Encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	// rawPath is escaped, Ex: /files/a%2Fb
+	path := event.RawPath
+	if path == "" {
+		path = event.RequestContext.HTTP.Path
+	}
+	u, err := url.ParseRequestURI(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "serverless: parse path")
+	}
+	u.RawQuery = event.RawQueryString
+
+	ctx = context.WithValue(ctx, eventV2Key, event)
+	r, err := newRequest(ctx, event.RequestContext.HTTP.Method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	// v2 joins the multi value headers with comma
+	for key, value := range event.Headers {
+		r.Header.Set(key, value)
+	}
+	if len(event.Cookies) > 0 {
+		r.Header.Set("Cookie", strings.Join(event.Cookies, "; "))
+	}
+	setRequestInfo(r, event.RequestContext.RequestID, event.RequestContext.HTTP.SourceIP)
+
+	res, body := a.serve(r)
+	resBody, isBase64 := responseBody(res.Header, body)
+
+	out := &APIGatewayV2HTTPResponse{
+		StatusCode:      res.StatusCode,
+		Headers:         make(map[string]string, len(res.Header)),
+		Body:            resBody,
+		IsBase64Encoded: isBase64,
+		Cookies:         res.Header.Values("Set-Cookie"),
+	}
+	for key, values := range res.Header {
+		if key == "Set-Cookie" {
+			continue
+		}
+		out.Headers[key] = strings.Join(values, ",")
+	}
+	return out, nil
+}
+
+// EventV1 returns the v1 proxy event of the request, if the request is
+// served by the adapter.
+func EventV1(r *http.Request) (*APIGatewayProxyRequest, bool) {
+	event, ok := r.Context().Value(eventV1Key).(*APIGatewayProxyRequest)
+	return event, ok
+}
+
+// EventV2 returns the v2 proxy event of the request, if the request is
+// served by the adapter.
+func EventV2(r *http.Request) (*APIGatewayV2HTTPRequest, bool) {
+	event, ok := r.Context().Value(eventV2Key).(*APIGatewayV2HTTPRequest)
+	return event, ok
+}
+
+// serve runs the handler and returns the response & its body
+func (a *Adapter) serve(r *http.Request) (*http.Response, []byte) {
+	rec := httptest.NewRecorder()
+	a.Handler.ServeHTTP(rec, r)
+	return rec.Result(), rec.Body.Bytes()
+}
+
+func newRequest(ctx context.Context, method string, u *url.URL, body []byte) (*http.Request, error) {
+	r, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "serverless: new request")
+	}
+	r.RequestURI = u.RequestURI()
+	return r, nil
+}
+
+func setRequestInfo(r *http.Request, requestID, sourceIP string) {
+	if r.Header.Get(middleware.RequestIDHeader) == "" && requestID != "" {
+		r.Header.Set(middleware.RequestIDHeader, requestID)
+	}
+	if host := r.Header.Get("Host"); host != "" {
+		r.Host = host
+	}
+	r.RemoteAddr = sourceIP
+}
+
+func eventBody(body string, isBase64 bool) ([]byte, error) {
+	if !isBase64 {
+		return []byte(body), nil
+	}
+
+	b, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return nil, errors.Wrap(err, "serverless: decode base64 body")
+	}
+	return b, nil
+}
+
+// responseBody returns the base64 encoded body for the binary content types
+func responseBody(header http.Header, body []byte) (string, bool) {
+	if isTextContent(header.Get("Content-Type")) {
+		return string(body), false
+	}
+
+	return base64.StdEncoding.EncodeToString(body), true
+}
+
+// isTextContent reports whether the content type can be sent as the plain body
+func isTextContent(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript",
+		"application/x-www-form-urlencoded", "image/svg+xml":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package serverless
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/manigandand/adk/middleware"
+)
+
+type echo struct {
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	EscapedPath string `json:"escaped_path"`
+	Param       string `json:"param"`
+	Query       string `json:"query"`
+	Body        string `json:"body"`
+	Cookie      string `json:"cookie"`
+	RequestID   string `json:"request_id"`
+	RemoteAddr  string `json:"remote_addr"`
+}
+
+func echoRouter() http.Handler {
+	handler := func(param string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(echo{
+				Method:      r.Method,
+				Path:        r.URL.Path,
+				EscapedPath: r.URL.EscapedPath(),
+				Param:       chi.URLParam(r, param),
+				Query:       r.URL.RawQuery,
+				Body:        string(body),
+				Cookie:      r.Header.Get("Cookie"),
+				RequestID:   r.Header.Get(middleware.RequestIDHeader),
+				RemoteAddr:  r.RemoteAddr,
+			})
+		}
+	}
+
+	r := chi.NewRouter()
+	r.Post("/user", handler(""))
+	r.Get("/files/{name}", handler("name"))
+	return r
+}
+
+func TestAdapterFixtureEvents(t *testing.T) {
+	tests := []struct {
+		file string
+		want echo
+	}{
+		{
+			file: "../examples/serverless/events/apigw_v1.json",
+			want: echo{
+				Method:      http.MethodPost,
+				Path:        "/user",
+				EscapedPath: "/user",
+				Query:       "source=mobile",
+				Body:        `{"email": " Gopher@Example.com ", "name": "gopher"}`,
+				RequestID:   "c6af9ac6-7b61-11e6-9a41-93e8deadbeef",
+				RemoteAddr:  "203.0.113.10",
+			},
+		},
+		{
+			file: "../examples/serverless/events/apigw_v2.json",
+			want: echo{
+				Method:      http.MethodPost,
+				Path:        "/user",
+				EscapedPath: "/user",
+				Query:       "source=mobile",
+				Body:        `{"email": " Gopher@Example.com ", "name": "gopher"}`,
+				Cookie:      "session=abc123",
+				RequestID:   "JKJaXmPLvHcESHA=",
+				RemoteAddr:  "203.0.113.10",
+			},
+		},
+	}
+
+	adapter := New(echoRouter())
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			payload, err := adapter.InvokeFile(context.Background(), tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertEcho(t, payload, tt.want)
+		})
+	}
+}
+
+func TestAdapterV2EscapedPath(t *testing.T) {
+	event := &APIGatewayV2HTTPRequest{
+		Version:        "2.0",
+		RawPath:        "/files/a%2Fb",
+		RawQueryString: "q=a%26b",
+	}
+	event.RequestContext.HTTP.Method = http.MethodGet
+	event.RequestContext.HTTP.Path = "/files/a/b"
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := New(echoRouter()).Invoke(context.Background(), payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEcho(t, res, echo{
+		Method:      http.MethodGet,
+		Path:        "/files/a/b",
+		EscapedPath: "/files/a%2Fb",
+		Param:       "a%2Fb",
+		Query:       "q=a%26b",
+	})
+}
+
+func assertEcho(t *testing.T, payload []byte, want echo) {
+	t.Helper()
+
+	var res struct {
+		StatusCode int    `json:"statusCode"`
+		Body       string `json:"body"`
+	}
+	if err := json.Unmarshal(payload, &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", res.StatusCode, res.Body)
+	}
+
+	var got echo
+	if err := json.Unmarshal([]byte(res.Body), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,101 @@
+package serverless
+
+// API Gateway proxy events, the JSON shapes are same as the lambda events of
+// the API Gateway REST API (v1) & HTTP API (v2) proxy integrations.
+// https://docs.aws.amazon.com/apigateway/latest/developerguide/http-api-develop-integrations-lambda.html
+
+// APIGatewayProxyRequest is the v1 (REST API) proxy event
+type APIGatewayProxyRequest struct {
+	Resource                        string                        `json:"resource"`
+	Path                            string                        `json:"path"`
+	HTTPMethod                      string                        `json:"httpMethod"`
+	Headers                         map[string]string             `json:"headers"`
+	MultiValueHeaders               map[string][]string           `json:"multiValueHeaders"`
+	QueryStringParameters           map[string]string             `json:"queryStringParameters"`
+	MultiValueQueryStringParameters map[string][]string           `json:"multiValueQueryStringParameters"`
+	PathParameters                  map[string]string             `json:"pathParameters"`
+	StageVariables                  map[string]string             `json:"stageVariables"`
+	RequestContext                  APIGatewayProxyRequestContext `json:"requestContext"`
+	Body                            string                        `json:"body"`
+	IsBase64Encoded                 bool                          `json:"isBase64Encoded"`
+}
+
+// APIGatewayProxyRequestContext is the request context of the v1 proxy event
+type APIGatewayProxyRequestContext struct {
+	AccountID    string                 `json:"accountId"`
+	ResourceID   string                 `json:"resourceId"`
+	Stage        string                 `json:"stage"`
+	RequestID    string                 `json:"requestId"`
+	DomainName   string                 `json:"domainName"`
+	APIID        string                 `json:"apiId"`
+	Identity     APIGatewayIdentity     `json:"identity"`
+	Authorizer   map[string]interface{} `json:"authorizer"`
+	ResourcePath string                 `json:"resourcePath"`
+	HTTPMethod   string                 `json:"httpMethod"`
+	RequestTime  string                 `json:"requestTime"`
+}
+
+// APIGatewayIdentity is the caller identity of the v1 proxy event
+type APIGatewayIdentity struct {
+	SourceIP  string `json:"sourceIp"`
+	UserAgent string `json:"userAgent"`
+}
+
+// APIGatewayProxyResponse is the response of the v1 proxy event
+type APIGatewayProxyResponse struct {
+	StatusCode        int                 `json:"statusCode"`
+	Headers           map[string]string   `json:"headers,omitempty"`
+	MultiValueHeaders map[string][]string `json:"multiValueHeaders,omitempty"`
+	Body              string              `json:"body"`
+	IsBase64Encoded   bool                `json:"isBase64Encoded"`
+}
+
+// APIGatewayV2HTTPRequest is the v2 (HTTP API) proxy event
+type APIGatewayV2HTTPRequest struct {
+	Version               string                         `json:"version"`
+	RouteKey              string                         `json:"routeKey"`
+	RawPath               string                         `json:"rawPath"`
+	RawQueryString        string                         `json:"rawQueryString"`
+	Cookies               []string                       `json:"cookies,omitempty"`
+	Headers               map[string]string              `json:"headers"`
+	QueryStringParameters map[string]string              `json:"queryStringParameters,omitempty"`
+	PathParameters        map[string]string              `json:"pathParameters,omitempty"`
+	StageVariables        map[string]string              `json:"stageVariables,omitempty"`
+	RequestContext        APIGatewayV2HTTPRequestContext `json:"requestContext"`
+	Body                  string                         `json:"body,omitempty"`
+	IsBase64Encoded       bool                           `json:"isBase64Encoded"`
+}
+
+// APIGatewayV2HTTPRequestContext is the request context of the v2 proxy event
+type APIGatewayV2HTTPRequestContext struct {
+	RouteKey     string                             `json:"routeKey"`
+	AccountID    string                             `json:"accountId"`
+	Stage        string                             `json:"stage"`
+	RequestID    string                             `json:"requestId"`
+	APIID        string                             `json:"apiId"`
+	DomainName   string                             `json:"domainName"`
+	DomainPrefix string                             `json:"domainPrefix"`
+	Time         string                             `json:"time"`
+	TimeEpoch    int64                              `json:"timeEpoch"`
+	HTTP         APIGatewayV2HTTPRequestContextHTTP `json:"http"`
+	Authorizer   map[string]interface{}             `json:"authorizer,omitempty"`
+}
+
+// APIGatewayV2HTTPRequestContextHTTP is the http info of the v2 proxy event
+type APIGatewayV2HTTPRequestContextHTTP struct {
+	Method    string `json:"method"`
+	Path      string `json:"path"`
+	Protocol  string `json:"protocol"`
+	SourceIP  string `json:"sourceIp"`
+	UserAgent string `json:"userAgent"`
+}
+
+// APIGatewayV2HTTPResponse is the response of the v2 proxy event
+type APIGatewayV2HTTPResponse struct {
+	StatusCode        int                 `json:"statusCode"`
+	Headers           map[string]string   `json:"headers,omitempty"`
+	MultiValueHeaders map[string][]string `json:"multiValueHeaders,omitempty"`
+	Body              string              `json:"body"`
+	IsBase64Encoded   bool                `json:"isBase64Encoded"`
+	Cookies           []string            `json:"cookies,omitempty"`
+}
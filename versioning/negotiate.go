@@ -0,0 +1,290 @@
+package versioning
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/manigandand/adk/api"
+	"github.com/manigandand/adk/errors"
+	"github.com/manigandand/adk/middleware"
+	log "github.com/sirupsen/logrus"
+)
+
+const negotiatedKey contextKey = "versioning.negotiated"
+
+// majorPattern matches the version segment of the path. Ex: /v2/users
+var majorPattern = regexp.MustCompile(`^v[0-9]+$`)
+
+// Deprecation describes the deprecated version or route, the Deprecation,
+// Sunset (RFC 8594) and Link headers are sent with the responses.
+type Deprecation struct {
+	// Deprecated is the time the version or route is deprecated
+	Deprecated time.Time
+	// Sunset is the time the version or route stops responding
+	Sunset time.Time
+	// Link is the URL of the migration guide
+	Link string
+}
+
+// SetHeaders sets the deprecation headers
+//
+//	Deprecation: @1717200000
+//	Sunset: Sat, 01 Mar 2025 00:00:00 GMT
+//	Link: <https://docs.example.com/migrate/v2>; rel="deprecation"; type="text/html"
+func (d *Deprecation) SetHeaders(h http.Header) {
+	if d.Deprecated.IsZero() {
+		h.Set("Deprecation", "true")
+	} else {
+		h.Set("Deprecation", fmt.Sprintf("@%d", d.Deprecated.Unix()))
+	}
+	if !d.Sunset.IsZero() {
+		h.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if d.Link != "" {
+		h.Add("Link", "<"+d.Link+`>; rel="deprecation"; type="text/html"`)
+		if !d.Sunset.IsZero() {
+			h.Add("Link", "<"+d.Link+`>; rel="sunset"; type="text/html"`)
+		}
+	}
+}
+
+// Deprecate returns the middleware which marks the route deprecated. The
+// deprecation headers are sent and the calls are counted in the logs.
+//
+// EX:
+//
+//	r.With(versioning.Deprecate(versioning.Deprecation{
+//		Sunset: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+//		Link:   "https://docs.example.com/migrate/users-search",
+//	})).Method(http.MethodGet, "/users/search", api.Handler(SearchUsersHandler))
+func Deprecate(d Deprecation) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			d.SetHeaders(w.Header())
+			next.ServeHTTP(w, r)
+			logDeprecatedCall(r, Negotiated(r.Context()))
+		})
+	}
+}
+
+var (
+	deprecatedMu    sync.Mutex
+	deprecatedCalls = map[string]int64{}
+)
+
+// logDeprecatedCall counts the calls of the deprecated version & route
+func logDeprecatedCall(r *http.Request, version string) {
+	route := r.Method + " " + api.RoutePattern(r)
+	key := strings.TrimSpace(version + " " + route)
+
+	deprecatedMu.Lock()
+	deprecatedCalls[key]++
+	calls := deprecatedCalls[key]
+	deprecatedMu.Unlock()
+
+	log.WithFields(log.Fields{
+		"version":    version,
+		"route":      route,
+		"calls":      calls,
+		"request_id": middleware.RequestID(r.Context()),
+	}).Warn("deprecated api call")
+}
+
+// DeprecatedCalls returns the number of the calls of the deprecated versions
+// & routes since the start, keyed by "<version> <method> <route pattern>".
+func DeprecatedCalls() map[string]int64 {
+	deprecatedMu.Lock()
+	defer deprecatedMu.Unlock()
+
+	calls := make(map[string]int64, len(deprecatedCalls))
+	for key, n := range deprecatedCalls {
+		calls[key] = n
+	}
+	return calls
+}
+
+// Major is the major version of the api, served by its own handler.
+type Major struct {
+	// Version is the name of the version, Ex: v2
+	Version string
+	Handler http.Handler
+	// Deprecation marks all the routes of the version deprecated
+	Deprecation *Deprecation
+}
+
+// Negotiator routes the request to the handler of the major version. The
+// version is negotiated in the order,
+//
+//	path prefix:   /v2/users
+//	Accept header: application/vnd.<vendor>.v2+json
+//	Header:        Accept-Version: v2
+//	Default
+//
+// The unsupported versions are responded with 406 listing the supported ones.
+//
+// EX:
+//
+//	n := versioning.NewNegotiator("acme", &versioning.Major{
+//		Version: "v1",
+//		Handler: v1Router,
+//		Deprecation: &versioning.Deprecation{
+//			Deprecated: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+//			Sunset:     time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+//			Link:       "https://docs.acme.com/migrate/v2",
+//		},
+//	}, &versioning.Major{Version: "v2", Handler: v2Router})
+//	http.ListenAndServe(":8080", n)
+type Negotiator struct {
+	// Vendor is the vendor of the media type, Ex: acme in application/vnd.acme.v2+json
+	Vendor string
+	// Header is the custom version header
+	Header string
+	// Default is the version of the requests without the version, the latest
+	// version by default.
+	Default string
+
+	majors map[string]*Major
+}
+
+// NewNegotiator returns the negotiator of the major versions, the last one is the default.
+func NewNegotiator(vendor string, majors ...*Major) *Negotiator {
+	n := &Negotiator{
+		Vendor: vendor,
+		Header: "Accept-Version",
+		majors: make(map[string]*Major, len(majors)),
+	}
+	for _, m := range majors {
+		n.majors[m.Version] = m
+		n.Default = m.Version
+	}
+	return n
+}
+
+// Negotiated returns the negotiated major version of the request
+func Negotiated(ctx context.Context) string {
+	version, _ := ctx.Value(negotiatedKey).(string)
+	return version
+}
+
+// Supported returns the supported major versions
+func (n *Negotiator) Supported() []string {
+	versions := make([]string, 0, len(n.majors))
+	for version := range n.majors {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		// v10 after v9
+		if len(versions[i]) != len(versions[j]) {
+			return len(versions[i]) < len(versions[j])
+		}
+		return versions[i] < versions[j]
+	})
+	return versions
+}
+
+// ServeHTTP implements http handler interface
+func (n *Negotiator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	api.Handler(n.serve).ServeHTTP(w, r)
+}
+
+func (n *Negotiator) serve(w http.ResponseWriter, r *http.Request) *errors.AppError {
+	w.Header().Add("Vary", "Accept")
+	if n.Header != "" {
+		w.Header().Add("Vary", n.Header)
+	}
+
+	version, r := n.negotiate(r)
+	major, ok := n.majors[version]
+	if !ok {
+		return errors.NotAcceptable(fmt.Sprintf("api version %s is not supported", version)).
+			AddConflictData(map[string]interface{}{
+				"supported": n.Supported(),
+			})
+	}
+
+	r = r.WithContext(context.WithValue(r.Context(), negotiatedKey, version))
+	if major.Deprecation == nil {
+		major.Handler.ServeHTTP(w, r)
+		return nil
+	}
+
+	major.Deprecation.SetHeaders(w.Header())
+	major.Handler.ServeHTTP(w, r)
+	logDeprecatedCall(r, version)
+	return nil
+}
+
+// negotiate returns the version of the request, the version prefix is
+// stripped from the path.
+func (n *Negotiator) negotiate(r *http.Request) (string, *http.Request) {
+	if version, rest, ok := n.pathVersion(r.URL.Path); ok {
+		return version, stripPath(r, version, rest)
+	}
+
+	if version := n.acceptVersion(r.Header.Get("Accept")); version != "" {
+		return version, r
+	}
+
+	if n.Header != "" {
+		if version := strings.TrimSpace(r.Header.Get(n.Header)); version != "" {
+			return version, r
+		}
+	}
+	return n.Default, r
+}
+
+// pathVersion returns the version of the first path segment. Ex: /v2/users
+func (n *Negotiator) pathVersion(path string) (string, string, bool) {
+	segment, rest, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if !majorPattern.MatchString(segment) {
+		return "", "", false
+	}
+	return segment, "/" + rest, true
+}
+
+// acceptVersion returns the version of the vendor media type. Ex: application/vnd.acme.v2+json
+func (n *Negotiator) acceptVersion(accept string) string {
+	if n.Vendor == "" {
+		return ""
+	}
+
+	prefix := "application/vnd." + n.Vendor + "."
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil || !strings.HasPrefix(mediaType, prefix) {
+			continue
+		}
+
+		version := strings.TrimSuffix(strings.TrimPrefix(mediaType, prefix), "+json")
+		if version != "" {
+			return version
+		}
+	}
+	return ""
+}
+
+// stripPath strips the version prefix from the path, and from the route path
+// of chi, if the negotiator is mounted on the chi router.
+func stripPath(r *http.Request, version, rest string) *http.Request {
+	r2 := r.Clone(r.Context())
+	r2.URL.Path = rest
+	if r.URL.RawPath != "" {
+		r2.URL.RawPath = strings.TrimPrefix(r.URL.RawPath, "/"+version)
+	}
+
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePath != "" {
+		rctx.RoutePath = strings.TrimPrefix(rctx.RoutePath, "/"+version)
+		if rctx.RoutePath == "" {
+			rctx.RoutePath = "/"
+		}
+	}
+	return r2
+}
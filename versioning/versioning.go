@@ -0,0 +1,434 @@
+package versioning
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/manigandand/adk/api"
+	"github.com/manigandand/adk/errors"
+)
+
+// DateLayout is the layout of the versions. Ex: 2024-06-01
+const DateLayout = "2006-01-02"
+
+// Header is the name of the HTTP Header which pins the api version of the
+// request. The resolved version is sent back in the same header.
+// Exported so that it can be changed by developers
+var Header = "X-Api-Version"
+
+type contextKey string
+
+const versionKey contextKey = "versioning.version"
+
+// Transform transforms the decoded JSON body, Ex: map[string]interface{} for
+// the objects and []interface{} for the arrays. The numbers are json.Number.
+type Transform func(r *http.Request, body interface{}) (interface{}, *errors.AppError)
+
+// Change is the backward incompatible change of the payloads, introduced in
+// the Version. The requests of the clients pinned to the older versions are
+// upgraded to the latest shape before api.Decode, and the responses & the app
+// errors are downgraded to the shape of the pinned version.
+//
+// EX:
+//
+//	var splitName = &versioning.Change{
+//		Version:     "2024-06-01",
+//		Description: "name is split into first_name & last_name",
+//		Routes:      []string{"POST /user", "GET /user/{id}"},
+//		UpgradeRequest: func(r *http.Request, body interface{}) (interface{}, *errors.AppError) {
+//			user := body.(map[string]interface{})
+//			first, last, _ := strings.Cut(fmt.Sprint(user["name"]), " ")
+//			user["first_name"], user["last_name"] = first, last
+//			delete(user, "name")
+//			return user, nil
+//		},
+//		DowngradeResponse: func(r *http.Request, body interface{}) (interface{}, *errors.AppError) {
+//			user := body.(map[string]interface{})
+//			user["name"] = fmt.Sprint(user["first_name"], " ", user["last_name"])
+//			delete(user, "first_name")
+//			delete(user, "last_name")
+//			return user, nil
+//		},
+//	}
+type Change struct {
+	// Version is the date the change is introduced. Ex: 2024-06-01
+	Version     string
+	Description string
+	// Routes limits the change to the route patterns, Ex: "/user/{id}" or
+	// "POST /user". The change applies to all the routes if it's empty.
+	Routes []string
+
+	// UpgradeRequest transforms the old request body into the new shape
+	UpgradeRequest Transform
+	// DowngradeResponse transforms the new success response into the old shape
+	DowngradeResponse Transform
+	// DowngradeError transforms the app error JSON into the old shape
+	DowngradeError Transform
+}
+
+// UpgradeJSON upgrades the raw JSON request body, used to test the change on its own
+func (c *Change) UpgradeJSON(r *http.Request, data []byte) ([]byte, *errors.AppError) {
+	return transformJSON(r, data, c.UpgradeRequest)
+}
+
+// DowngradeJSON downgrades the raw JSON response body of the status, used to
+// test the change on its own
+func (c *Change) DowngradeJSON(r *http.Request, status int, data []byte) ([]byte, *errors.AppError) {
+	return transformJSON(r, data, c.downgrade(status))
+}
+
+func (c *Change) downgrade(status int) Transform {
+	if status >= http.StatusBadRequest {
+		return c.DowngradeError
+	}
+	return c.DowngradeResponse
+}
+
+// matches reports whether the change applies to the route of the request
+func (c *Change) matches(r *http.Request) bool {
+	if len(c.Routes) == 0 {
+		return true
+	}
+
+	pattern := api.RoutePattern(r)
+	for _, route := range c.Routes {
+		if route == pattern || route == r.Method+" "+pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// Versions is the chain of the changes. The version of the request is pinned
+// by the Header, or the AccountDefault, or the latest version.
+//
+// EX:
+//
+//	versions := versioning.New(splitName, renameStatus)
+//	versions.AccountDefault = func(r *http.Request) string {
+//		return auth.Account(r.Context()).APIVersion
+//	}
+//	r.Use(versions.Middleware)
+type Versions struct {
+	// AccountDefault returns the version pinned for the account of the
+	// request, "" falls back to the latest version. The version must be the
+	// version of a change, the rest fails with the internal error.
+	AccountDefault func(r *http.Request) string
+
+	changes []*Change // sorted by the version
+}
+
+// New returns the versions of the changes. It panics if the version of a
+// change is not a date, as it's the programming error.
+func New(changes ...*Change) *Versions {
+	for _, c := range changes {
+		if _, err := time.Parse(DateLayout, c.Version); err != nil {
+			panic("versioning: invalid version " + c.Version + " of the change " + c.Description)
+		}
+	}
+
+	sorted := append([]*Change(nil), changes...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+	return &Versions{
+		changes: sorted,
+	}
+}
+
+// Latest returns the latest version, "" if there are no changes
+func (v *Versions) Latest() string {
+	if len(v.changes) == 0 {
+		return ""
+	}
+	return v.changes[len(v.changes)-1].Version
+}
+
+// Supported returns the versions of the changes, oldest first
+func (v *Versions) Supported() []string {
+	var versions []string
+	for _, c := range v.changes {
+		if len(versions) == 0 || versions[len(versions)-1] != c.Version {
+			versions = append(versions, c.Version)
+		}
+	}
+	return versions
+}
+
+// Version returns the pinned version of the request
+func Version(ctx context.Context) string {
+	version, _ := ctx.Value(versionKey).(string)
+	return version
+}
+
+// WithVersion returns the context with the pinned version
+func WithVersion(ctx context.Context, version string) context.Context {
+	return context.WithValue(ctx, versionKey, version)
+}
+
+// Middleware pins the version of the request, upgrades the request body and
+// downgrades the JSON response to the pinned version. The requests of the
+// latest version are served as it is. Only the JSON responses which have a
+// downgrade are buffered, the streams, Ex: SSE, and the websocket upgrades
+// are passed through.
+func (v *Versions) Middleware(next http.Handler) http.Handler {
+	return api.Handler(func(w http.ResponseWriter, r *http.Request) *errors.AppError {
+		version, err := v.resolve(r)
+		if err != nil {
+			return err
+		}
+
+		r = r.WithContext(WithVersion(r.Context(), version))
+		if version != "" {
+			w.Header().Set(Header, version)
+		}
+
+		changes := v.since(version)
+		if len(changes) == 0 {
+			next.ServeHTTP(w, r)
+			return nil
+		}
+
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = &upgradeBody{
+				ReadCloser: r.Body,
+				r:          r,
+				changes:    changes,
+			}
+		}
+
+		rec := newDowngradeWriter(w, r, changes)
+		next.ServeHTTP(rec, r)
+		if !rec.buffered {
+			return nil
+		}
+		return writeDowngraded(w, r, rec, changes)
+	})
+}
+
+// resolve returns the pinned version of the request
+func (v *Versions) resolve(r *http.Request) (string, *errors.AppError) {
+	if version := strings.TrimSpace(r.Header.Get(Header)); version != "" {
+		if _, err := time.Parse(DateLayout, version); err != nil {
+			return "", errors.InvalidKey(version, Header).AddDebug(err)
+		}
+		return version, nil
+	}
+
+	if v.AccountDefault != nil {
+		if version := v.AccountDefault(r); version != "" {
+			if !v.declared(version) {
+				return "", errors.InternalServerStd().
+					AddDebugf("versioning: account default version %q is not declared", version)
+			}
+			return version, nil
+		}
+	}
+	return v.Latest(), nil
+}
+
+// declared reports whether the version is the version of a change
+func (v *Versions) declared(version string) bool {
+	i := sort.Search(len(v.changes), func(i int) bool {
+		return v.changes[i].Version >= version
+	})
+	return i < len(v.changes) && v.changes[i].Version == version
+}
+
+// since returns the changes introduced after the version, oldest first
+func (v *Versions) since(version string) []*Change {
+	i := sort.Search(len(v.changes), func(i int) bool {
+		return v.changes[i].Version > version
+	})
+	return v.changes[i:]
+}
+
+// upgradeBody upgrades the request body on the first read, by then the router
+// has matched the route, so the route patterns of the changes are resolved.
+type upgradeBody struct {
+	io.ReadCloser
+	r       *http.Request
+	changes []*Change
+
+	body *bytes.Reader
+	err  error
+}
+
+func (b *upgradeBody) Read(p []byte) (int, error) {
+	if b.body == nil && b.err == nil {
+		b.upgrade()
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.body.Read(p)
+}
+
+func (b *upgradeBody) upgrade() {
+	data, err := io.ReadAll(b.ReadCloser)
+	if err != nil {
+		b.err = err
+		return
+	}
+
+	// oldest first, each change upgrades to the next version
+	for _, c := range b.changes {
+		if c.UpgradeRequest == nil || !c.matches(b.r) {
+			continue
+		}
+		upgraded, appErr := transformJSON(b.r, data, c.UpgradeRequest)
+		if appErr != nil {
+			b.err = appErr
+			return
+		}
+		data = upgraded
+	}
+	b.body = bytes.NewReader(data)
+}
+
+// writeDowngraded writes the buffered response downgraded by the changes,
+// latest first.
+func writeDowngraded(w http.ResponseWriter, r *http.Request, rec *downgradeWriter, changes []*Change) *errors.AppError {
+	data := rec.body.Bytes()
+	for i := len(changes) - 1; i >= 0; i-- {
+		c := changes[i]
+		if !c.matches(r) {
+			continue
+		}
+		downgraded, err := transformJSON(r, data, c.downgrade(rec.code))
+		if err != nil {
+			return err
+		}
+		data = downgraded
+	}
+
+	w.Header().Del("Content-Length")
+	w.WriteHeader(rec.code)
+	if _, err := w.Write(data); err != nil {
+		return errors.InternalServer("couldn't write the response").AddDebug(err)
+	}
+	return nil
+}
+
+// transformJSON applies the transform on the JSON payload. The empty & the
+// invalid JSON payloads are returned as it is, api.Decode reports them.
+func transformJSON(r *http.Request, data []byte, fn Transform) ([]byte, *errors.AppError) {
+	if fn == nil || len(bytes.TrimSpace(data)) == 0 {
+		return data, nil
+	}
+
+	var body interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&body); err != nil {
+		return data, nil
+	}
+
+	body, appErr := fn(r, body)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	// same encoding as respond
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(body); err != nil {
+		return nil, errors.InternalServer("couldn't encode the versioned payload").AddDebug(err)
+	}
+	return buf.Bytes(), nil
+}
+
+func isJSON(contentType string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// downgradeWriter buffers the JSON response to be downgraded, decided by
+// the status & the content type once the header is written. The rest of the
+// responses are passed through.
+type downgradeWriter struct {
+	http.ResponseWriter
+	r       *http.Request
+	changes []*Change
+
+	body        bytes.Buffer
+	code        int
+	wroteHeader bool
+	buffered    bool
+}
+
+func newDowngradeWriter(w http.ResponseWriter, r *http.Request, changes []*Change) *downgradeWriter {
+	return &downgradeWriter{
+		ResponseWriter: w,
+		r:              r,
+		changes:        changes,
+		code:           http.StatusOK,
+	}
+}
+
+// WriteHeader implements http.ResponseWriter
+func (rec *downgradeWriter) WriteHeader(statusCode int) {
+	if rec.wroteHeader {
+		return
+	}
+	rec.code = statusCode
+	rec.wroteHeader = true
+
+	rec.buffered = statusCode != http.StatusNoContent &&
+		isJSON(rec.Header().Get("Content-Type")) &&
+		hasDowngrade(rec.r, rec.changes, statusCode)
+	if !rec.buffered {
+		rec.ResponseWriter.WriteHeader(statusCode)
+	}
+}
+
+// Write implements http.ResponseWriter
+func (rec *downgradeWriter) Write(b []byte) (int, error) {
+	rec.WriteHeader(http.StatusOK)
+	if rec.buffered {
+		return rec.body.Write(b)
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+// Hijack implements http.Hijacker, required by the websocket upgrade
+func (rec *downgradeWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rec.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("versioning: responsewriter doesn't implement http.Hijacker")
+	}
+	return hijacker.Hijack()
+}
+
+// Flush implements http.Flusher, the buffered responses are written at once
+func (rec *downgradeWriter) Flush() {
+	rec.WriteHeader(http.StatusOK)
+	if rec.buffered {
+		return
+	}
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the underlying responsewriter, used by http.ResponseController
+func (rec *downgradeWriter) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// hasDowngrade reports whether any of the changes downgrades the response
+func hasDowngrade(r *http.Request, changes []*Change, status int) bool {
+	for _, c := range changes {
+		if c.downgrade(status) != nil && c.matches(r) {
+			return true
+		}
+	}
+	return false
+}